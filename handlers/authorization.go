@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"robot-apocalypse/pkg/models"
+)
+
+// role based access control
+// the survivors can only act on their own record, the field officers and
+// the admins can act on every survivor
+
+// the principal should have one of the roles
+func authorizeRole(principal models.Principal, roles ...models.Role) error {
+	for _, role := range roles {
+		if principal.Role == role {
+			return nil
+		}
+	}
+	return Forbidden(CodeRoleNotAllowed, fmt.Errorf("role %q is not allowed to perform this action", principal.Role))
+}
+
+// the principal should be allowed to act on the survivor
+func authorizeSurvivor(principal models.Principal, survivorID string) error {
+	if principal.Role != models.RoleSurvivor {
+		return authorizeRole(principal, models.RoleFieldOfficer, models.RoleAdmin)
+	}
+	if principal.SurvivorID == "" || principal.SurvivorID != survivorID {
+		return Forbidden(CodeNotOwnRecord, errors.New("survivors can only act on their own record"))
+	}
+	return nil
+}
+
+// survivors can only give away items, the items are only added by the
+// field officers and the admins, the survivors get new items with the trades
+func authorizeInventoryChange(principal models.Principal, changes models.Resources) error {
+	if principal.Role != models.RoleSurvivor {
+		return nil
+	}
+	for item, quantity := range changes {
+		if quantity > 0 {
+			return Forbidden(CodeRoleNotAllowed, fmt.Errorf("survivors can't add %s to their inventory, use a trade", item))
+		}
+	}
+	return nil
+}
+
+// survivor id of the request, the survivors always act as themselves, so
+// the id of the request body is replaced with the id of the principal
+func survivorOf(principal models.Principal, survivorID string) string {
+	if principal.Role == models.RoleSurvivor {
+		return principal.SurvivorID
+	}
+	return survivorID
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"robot-apocalypse/pkg/db"
+	"robot-apocalypse/pkg/models"
+	"robot-apocalypse/pkg/validation"
+)
+
+// kind of the handler error
+// decides the http status code of the response
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindBadRequest
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindForbidden
+	KindUnauthorized
+	KindBadGateway
+)
+
+// machine readable error codes
+const (
+	CodeInternal               = "internal_error"
+	CodeBadRequest             = "bad_request"
+	CodeValidation             = "validation_failed"
+	CodeSurvivorNotFound       = "survivor_not_found"
+	CodeSurvivorExists         = "survivor_exists"
+	CodeReporterNotFound       = "reporter_not_found"
+	CodeInsufficientStock      = "insufficient_stock"
+	CodeInventoryLocked        = "inventory_locked"
+	CodeInventoryNotLocked     = "inventory_not_locked"
+	CodeAlreadyReported        = "already_reported"
+	CodeReportNotFound         = "report_not_found"
+	CodeNothingToAppeal        = "nothing_to_appeal"
+	CodeAppealNotFound         = "appeal_not_found"
+	CodeAppealPending          = "appeal_pending"
+	CodeAppealResolved         = "appeal_resolved"
+	CodeInvalidCursor          = "invalid_cursor"
+	CodeTradeUnbalanced        = "trade_unbalanced"
+	CodeTradeNotFound          = "trade_not_found"
+	CodeTradeResolved          = "trade_resolved"
+	CodeIdempotencyKeyReused   = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse    = "idempotency_key_in_use"
+	CodeMissingToken           = "missing_token"
+	CodeInvalidToken           = "invalid_token"
+	CodeInsufficientScope      = "insufficient_scope"
+	CodeAPIKeyNotFound         = "api_key_not_found"
+	CodeRoleNotAllowed         = "role_not_allowed"
+	CodeNotOwnRecord           = "not_own_record"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeInvalidRefreshToken    = "invalid_refresh_token"
+	CodeRobotSourceUnavailable = "robot_source_unavailable"
+	CodeRobotFeedEmpty         = "robot_feed_empty"
+	CodeRobotNotFound          = "robot_not_found"
+)
+
+// handler error
+// the message and the field errors are returned to the client, the wrapped
+// error is only for the logs
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  []models.FieldError
+	Err     error
+}
+
+// error message
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+// wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// http status code of the error
+func (e *Error) StatusCode() int {
+	switch e.Kind {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindBadGateway:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// malformed request, like unparsable body or query values
+func BadRequest(code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindBadRequest, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// requested entry doesn't exist
+func NotFound(code string, err error) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: err.Error(), Err: err}
+}
+
+// request conflicts with the current state of the entry
+func Conflict(code string, err error) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: err.Error(), Err: err}
+}
+
+// request payload failed the validation rules
+func Validation(fields validation.Errors) *Error {
+	return &Error{Kind: KindValidation, Code: CodeValidation, Message: "validation failed", Fields: fields, Err: fields}
+}
+
+// request is not allowed
+func Forbidden(code string, err error) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: err.Error(), Err: err}
+}
+
+// caller is not authenticated
+func Unauthorized(code string, err error) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: err.Error(), Err: err}
+}
+
+// upstream service failed, the client gets a generic message
+func BadGateway(code string, err error) *Error {
+	return &Error{Kind: KindBadGateway, Code: code, Message: "upstream service is unavailable", Err: err}
+}
+
+// unexpected failure, the client gets a generic message
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: CodeInternal, Message: "unable to process your request", Err: err}
+}
+
+// convert the validation and storage errors to the handler error
+// the unknown errors are internal errors
+func domainError(err error) error {
+	var handlerError *Error
+	var fieldErrors validation.Errors
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &handlerError):
+		return handlerError
+	case errors.As(err, &fieldErrors):
+		return Validation(fieldErrors)
+	case errors.Is(err, db.ErrSurvivorNotFound):
+		return NotFound(CodeSurvivorNotFound, err)
+	case errors.Is(err, db.ErrReportNotFound):
+		return NotFound(CodeReportNotFound, err)
+	case errors.Is(err, db.ErrAppealNotFound):
+		return NotFound(CodeAppealNotFound, err)
+	case errors.Is(err, db.ErrRobotNotFound):
+		return NotFound(CodeRobotNotFound, err)
+	case errors.Is(err, db.ErrTradeNotFound):
+		return NotFound(CodeTradeNotFound, err)
+	case errors.Is(err, db.ErrAPIKeyNotFound):
+		return NotFound(CodeAPIKeyNotFound, err)
+	case errors.Is(err, db.ErrSurvivorExists):
+		return Conflict(CodeSurvivorExists, err)
+	case errors.Is(err, db.ErrInsufficientStock):
+		return Conflict(CodeInsufficientStock, err)
+	case errors.Is(err, db.ErrInventoryLocked):
+		return Conflict(CodeInventoryLocked, err)
+	case errors.Is(err, db.ErrInventoryNotLocked):
+		return Conflict(CodeInventoryNotLocked, err)
+	case errors.Is(err, db.ErrAlreadyReported):
+		return Conflict(CodeAlreadyReported, err)
+	case errors.Is(err, db.ErrAppealPending):
+		return Conflict(CodeAppealPending, err)
+	case errors.Is(err, db.ErrAppealResolved):
+		return Conflict(CodeAppealResolved, err)
+	case errors.Is(err, db.ErrTradeResolved):
+		return Conflict(CodeTradeResolved, err)
+	case errors.Is(err, db.ErrEmptyRobotList):
+		return BadGateway(CodeRobotFeedEmpty, err)
+	case errors.Is(err, db.ErrInvalidCursor):
+		return BadRequest(CodeInvalidCursor, "%v", err)
+	default:
+		return Internal(err)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"robot-apocalypse/pkg/db"
+	"robot-apocalypse/pkg/validation"
+	"testing"
+)
+
+func TestDomainErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{validation.Errors{{Field: "age", Message: "is required"}}, http.StatusUnprocessableEntity, CodeValidation},
+		{db.ErrSurvivorNotFound, http.StatusNotFound, CodeSurvivorNotFound},
+		{db.ErrReportNotFound, http.StatusNotFound, CodeReportNotFound},
+		{db.ErrAppealNotFound, http.StatusNotFound, CodeAppealNotFound},
+		{db.ErrRobotNotFound, http.StatusNotFound, CodeRobotNotFound},
+		{db.ErrTradeNotFound, http.StatusNotFound, CodeTradeNotFound},
+		{db.ErrAPIKeyNotFound, http.StatusNotFound, CodeAPIKeyNotFound},
+		{db.ErrSurvivorExists, http.StatusConflict, CodeSurvivorExists},
+		{db.ErrInsufficientStock, http.StatusConflict, CodeInsufficientStock},
+		{db.ErrInventoryLocked, http.StatusConflict, CodeInventoryLocked},
+		{db.ErrInventoryNotLocked, http.StatusConflict, CodeInventoryNotLocked},
+		{db.ErrAlreadyReported, http.StatusConflict, CodeAlreadyReported},
+		{db.ErrAppealPending, http.StatusConflict, CodeAppealPending},
+		{db.ErrAppealResolved, http.StatusConflict, CodeAppealResolved},
+		{db.ErrTradeResolved, http.StatusConflict, CodeTradeResolved},
+		{db.ErrEmptyRobotList, http.StatusBadGateway, CodeRobotFeedEmpty},
+		{db.ErrInvalidCursor, http.StatusBadRequest, CodeInvalidCursor},
+		// the wrapped storage errors keep their status
+		{fmt.Errorf("trade of srv1: %w", db.ErrInsufficientStock), http.StatusConflict, CodeInsufficientStock},
+		// the handler errors are kept as they are
+		{Forbidden(CodeNotOwnRecord, errors.New("not own record")), http.StatusForbidden, CodeNotOwnRecord},
+		{Unauthorized(CodeInvalidToken, errors.New("invalid token")), http.StatusUnauthorized, CodeInvalidToken},
+		{errors.New("connection reset"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, test := range tests {
+		var handlerError *Error
+		if !errors.As(domainError(test.err), &handlerError) {
+			t.Errorf("%v: not converted to a handler error", test.err)
+			continue
+		}
+		if handlerError.StatusCode() != test.status || handlerError.Code != test.code {
+			t.Errorf("%v: got %d %s, want %d %s", test.err, handlerError.StatusCode(), handlerError.Code, test.status, test.code)
+		}
+	}
+
+	if domainError(nil) != nil {
+		t.Error("nil error is converted to a handler error")
+	}
+}
+
+func TestInternalErrorHidesDetails(t *testing.T) {
+	err := domainError(errors.New("mongo: connection refused"))
+	var handlerError *Error
+	if !errors.As(err, &handlerError) {
+		t.Fatalf("%v: not converted to a handler error", err)
+	}
+	if handlerError.Message != "unable to process your request" {
+		t.Errorf("message = %q, the details should only be logged", handlerError.Message)
+	}
+}
@@ -2,83 +2,1007 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"robot-apocalypse/pkg/alerts"
+	"robot-apocalypse/pkg/auth"
 	"robot-apocalypse/pkg/db"
 	"robot-apocalypse/pkg/models"
+	"robot-apocalypse/pkg/robotsource"
+	"robot-apocalypse/pkg/validation"
+	"sort"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// page size of the paginated listings
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// threat map defaults, the cell size is in degrees
+const (
+	defaultThreatCellSize  = 0.1
+	minThreatCellSize      = 0.001
+	maxThreatCellSize      = 10
+	defaultThreatMapWindow = 24 * time.Hour
 )
 
 // handler struct
 type Handler struct {
 	Logger *zap.Logger
-	DB     *db.MongoAdapter
+	DB     db.Services
+	Tokens *auth.Issuer
+	Robots robotsource.Source
+	Alerts *alerts.Broker
 }
 
 // initiate new handler
-func NewHandler(logger *zap.Logger, db *db.MongoAdapter) *Handler {
+func NewHandler(logger *zap.Logger, db db.Services, tokens *auth.Issuer, robots robotsource.Source, alerts *alerts.Broker) *Handler {
 	return &Handler{
 		Logger: logger,
 		DB:     db,
+		Tokens: tokens,
+		Robots: robots,
+		Alerts: alerts,
 	}
 }
 
 // new survivor handler
 // create new survivor entry to the database
-func (handle *Handler) NewSurvivorHandler(sr models.Survivor) error {
-	//check user id already exists
-	exists, err := handle.DB.Survivors().CheckSurvivorExists(sr.ID)
+// only the field officers and the admins can register survivors
+func (handle *Handler) NewSurvivorHandler(principal models.Principal, sr models.Survivor) (*models.Survivor, error) {
+	if err := authorizeRole(principal, models.RoleFieldOfficer, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	// validate the payload
+	if err := validation.Survivor(sr); err != nil {
+		return nil, domainError(err)
+	}
+	// the id is generated when the client doesn't have one
+	if sr.ID == "" {
+		sr.ID = primitive.NewObjectID().Hex()
+	}
+	// keep an empty inventory, so the items can be added later
+	if sr.Resources == nil {
+		sr.Resources = models.Resources{}
+	}
+	// new survivors starts without infection reports
+	sr.ReportedCount = 0
+	sr.ReportedBy = []string{}
+	sr.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	// only the hash of the password is kept, in a separate collection
+	var passwordHash []byte
+	if sr.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(sr.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, Internal(err)
+		}
+		passwordHash = hash
+		sr.Password = ""
+	}
+
+	// create new survivor, the unique id index rejects the existing ids
+	err := handle.DB.Survivors().New(sr)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	if passwordHash != nil {
+		err = handle.DB.Credentials().Set(models.SurvivorCredentials{
+			SurvivorID:   sr.ID,
+			PasswordHash: passwordHash,
+			UpdatedAt:    sr.CreatedAt,
+		})
+		if err != nil {
+			return nil, Internal(fmt.Errorf("unable to store the credentials of %s: %w", sr.ID, err))
+		}
+	}
+	return &sr, nil
+}
+
+// reserve idempotency key
+// returns the existing record when the key is already used, nil when the key
+// is reserved for the request
+func (handle *Handler) ReserveIdempotencyKeyHandler(key string, fingerprint string) (*models.IdempotencyRecord, error) {
+	record, err := handle.DB.Idempotency().Reserve(key, fingerprint)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return record, nil
+}
+
+// complete idempotency key
+// keep the response, so the retried requests gets the same response
+func (handle *Handler) CompleteIdempotencyKeyHandler(key string, statusCode int, body []byte) error {
+	return handle.DB.Idempotency().Complete(key, statusCode, body)
+}
+
+// release idempotency key
+// the request failed before producing a result, so it can be retried
+func (handle *Handler) ReleaseIdempotencyKeyHandler(key string) error {
+	return handle.DB.Idempotency().Release(key)
+}
+
+// sha256 hash of the api keys and the refresh tokens, only the hashes are
+// stored
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// random token of the api keys and the refresh tokens
+func randomToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// authenticate handler
+// returns the principal of the active api key
+func (handle *Handler) AuthenticateHandler(token string) (*models.Principal, error) {
+	if token == "" {
+		return nil, Unauthorized(CodeMissingToken, errors.New("TOKEN header is required"))
+	}
+	key, err := handle.DB.APIKeys().GetByHash(hashToken(token))
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if key == nil {
+		return nil, Unauthorized(CodeInvalidToken, errors.New("invalid or revoked api key"))
+	}
+	return &models.Principal{
+		ID:         key.ID,
+		Role:       key.Role,
+		SurvivorID: key.SurvivorID,
+		Scopes:     key.Scopes,
+	}, nil
+}
+
+// bearer token authentication
+// survivors are authenticated with the access tokens, the survivor id is the
+// subject of the token
+func (handle *Handler) AuthenticateBearerHandler(token string) (*models.Principal, error) {
+	subject, err := handle.Tokens.Parse(token)
+	if err != nil {
+		return nil, Unauthorized(CodeInvalidToken, fmt.Errorf("invalid access token: %w", err))
+	}
+	return &models.Principal{
+		ID:         subject,
+		Role:       models.RoleSurvivor,
+		SurvivorID: subject,
+		Scopes:     []models.Scope{models.ScopeSurvivorsWrite},
+	}, nil
+}
+
+// login handler
+// exchange the survivor credentials with a token pair
+func (handle *Handler) LoginHandler(login models.Login) (*models.TokenPair, error) {
+	if err := validation.Login(login); err != nil {
+		return nil, domainError(err)
+	}
+
+	credentials, err := handle.DB.Credentials().Get(login.ID)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	// same error for the unknown survivors and the wrong passwords
+	if credentials == nil || bcrypt.CompareHashAndPassword(credentials.PasswordHash, []byte(login.Password)) != nil {
+		return nil, Unauthorized(CodeInvalidCredentials, errors.New("invalid survivor id or password"))
+	}
+	return handle.issueTokens(login.ID)
+}
+
+// refresh handler
+// the refresh token can be used once, a new token pair is returned
+func (handle *Handler) RefreshHandler(refresh models.Refresh) (*models.TokenPair, error) {
+	if err := validation.Refresh(refresh); err != nil {
+		return nil, domainError(err)
+	}
+
+	token, err := handle.DB.Credentials().ConsumeRefreshToken(hashToken(refresh.RefreshToken))
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if token == nil {
+		return nil, Unauthorized(CodeInvalidRefreshToken, errors.New("invalid, used or expired refresh token"))
+	}
+	return handle.issueTokens(token.SurvivorID)
+}
+
+// logout handler
+// revoke the refresh token, the access token stays valid until it expires
+func (handle *Handler) LogoutHandler(refresh models.Refresh) error {
+	if err := validation.Refresh(refresh); err != nil {
+		return domainError(err)
+	}
+	if _, err := handle.DB.Credentials().ConsumeRefreshToken(hashToken(refresh.RefreshToken)); err != nil {
+		return Internal(err)
+	}
+	return nil
+}
+
+// new access token and refresh token of the survivor
+func (handle *Handler) issueTokens(survivorID string) (*models.TokenPair, error) {
+	accessToken, err := handle.Tokens.Sign(survivorID)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, Internal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err = handle.DB.Credentials().NewRefreshToken(models.RefreshToken{
+		SurvivorID: survivorID,
+		Hash:       hashToken(refreshToken),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(handle.Tokens.RefreshTTL()),
+	})
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(handle.Tokens.AccessTTL().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// bootstrap admin api key
+// stores the configured admin key, so the first api keys can be created. The
+// id is derived from the hash, a revoked bootstrap key stays revoked. The
+// key stored by the versions without the roles gets the admin role
+func (handle *Handler) BootstrapAPIKeyHandler(token string) error {
+	hash := hashToken(token)
+	id := "bootstrap-" + hash[:12]
+	err := handle.DB.APIKeys().New(models.APIKey{
+		ID:        id,
+		Name:      "bootstrap admin",
+		Hash:      hash,
+		Scopes:    []models.Scope{models.ScopeAdmin},
+		Role:      models.RoleAdmin,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	})
+	if errors.Is(err, db.ErrAPIKeyExists) {
+		return handle.DB.APIKeys().SetRole(id, models.RoleAdmin)
+	}
+	return err
+}
+
+// new api key handler
+// generates a random key, only the hash of the key is stored
+func (handle *Handler) NewAPIKeyHandler(principal models.Principal, request models.APIKey) (*models.NewAPIKey, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := validation.APIKey(request); err != nil {
+		return nil, domainError(err)
+	}
+	// survivor keys are bound to an existing survivor
+	if request.Role == models.RoleSurvivor {
+		exists, err := handle.DB.Survivors().CheckSurvivorExists(request.SurvivorID)
+		if err != nil {
+			return nil, Internal(err)
+		}
+		if !exists {
+			return nil, domainError(db.ErrSurvivorNotFound)
+		}
+	} else {
+		request.SurvivorID = ""
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, Internal(err)
+	}
+
+	key := models.APIKey{
+		ID:         primitive.NewObjectID().Hex(),
+		Name:       request.Name,
+		Hash:       hashToken(token),
+		Scopes:     request.Scopes,
+		Role:       request.Role,
+		SurvivorID: request.SurvivorID,
+		CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if err := handle.DB.APIKeys().New(key); err != nil {
+		return nil, domainError(err)
+	}
+	return &models.NewAPIKey{APIKey: key, Token: token}, nil
+}
+
+// list api keys handler
+func (handle *Handler) ListAPIKeysHandler(principal models.Principal) ([]models.APIKey, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	keys, err := handle.DB.APIKeys().List()
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	return keys, nil
+}
+
+// revoke api key handler
+func (handle *Handler) RevokeAPIKeyHandler(principal models.Principal, id string) (*models.APIKey, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	key, err := handle.DB.APIKeys().Revoke(id)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return key, nil
+}
+
+// fetch survivor handler
+// fetch the survivor details with the id
+func (handle *Handler) GetSurvivorHandler(id string) (*models.Survivor, error) {
+	survivor, err := handle.DB.Survivors().GetSurvivor(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if survivor == nil {
+		return nil, domainError(db.ErrSurvivorNotFound)
+	}
+	return survivor, nil
+}
+
+// list survivors handler
+// filtered, sorted and paginated list of the survivors
+func (handle *Handler) ListSurvivorsHandler(query models.SurvivorQuery) (*models.SurvivorPage, error) {
+	switch query.Status {
+	case "", "infected", "non-infected":
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid status %v", query.Status)
+	}
+	if query.MinAge < 0 || query.MaxAge < 0 || (query.MaxAge > 0 && query.MinAge > query.MaxAge) {
+		return nil, BadRequest(CodeBadRequest, "invalid age range")
+	}
+	if query.Resource != "" && !validation.IsResourceItem(query.Resource) {
+		return nil, BadRequest(CodeBadRequest, "unknown resource item %q", query.Resource)
+	}
+	switch query.SortBy {
+	case "":
+		query.SortBy = "created"
+	case "name", "age", "created":
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid sort field %v", query.SortBy)
+	}
+	switch query.Order {
+	case "":
+		query.Order = "asc"
+	case "asc", "desc":
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid sort order %v", query.Order)
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultPageSize
+	case query.Limit < 0 || query.Limit > maxPageSize:
+		return nil, BadRequest(CodeBadRequest, "limit should be between 1 and %d", maxPageSize)
+	}
+
+	page, err := handle.DB.Survivors().ListSurvivors(query)
 	if err != nil {
-		return fmt.Errorf("unable to process your request")
+		return nil, domainError(err)
+	}
+	return page, nil
+}
+
+// nearby survivors handler
+// survivors within the radius of the point, closest first
+func (handle *Handler) NearbySurvivorsHandler(query models.NearbyQuery) ([]models.NearbySurvivor, error) {
+	if query.Latitude == nil || query.Longitude == nil {
+		return nil, BadRequest(CodeBadRequest, "lat and lon are required")
 	}
-	if exists {
-		return fmt.Errorf("survivor entry already exists")
+	if *query.Latitude < -90 || *query.Latitude > 90 || *query.Longitude < -180 || *query.Longitude > 180 {
+		return nil, BadRequest(CodeBadRequest, "invalid lat or lon")
+	}
+	if query.Radius <= 0 {
+		return nil, BadRequest(CodeBadRequest, "radius should be greater than zero")
+	}
+	switch query.Status {
+	case "", "infected", "non-infected":
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid status %v", query.Status)
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultPageSize
+	case query.Limit < 0 || query.Limit > maxPageSize:
+		return nil, BadRequest(CodeBadRequest, "limit should be between 1 and %d", maxPageSize)
 	}
 
-	// create new survivor
-	return handle.DB.Survivors().New(sr)
+	survivors, err := handle.DB.Survivors().Nearby(query)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return survivors, nil
+}
+
+// survivor location history handler
+// escape track of the survivor within the time range, as the list of the
+// history entries or as a GeoJSON LineString feature
+func (handle *Handler) LocationHistoryHandler(id string, from string, to string, format string) (interface{}, error) {
+	var fromTime, toTime time.Time
+	var err error
+	if from != "" {
+		if fromTime, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, BadRequest(CodeBadRequest, "invalid from time, should be RFC3339")
+		}
+	}
+	if to != "" {
+		if toTime, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, BadRequest(CodeBadRequest, "invalid to time, should be RFC3339")
+		}
+	}
+	if format != "" && format != "json" && format != "geojson" {
+		return nil, BadRequest(CodeBadRequest, "invalid format %v", format)
+	}
+
+	exists, err := handle.DB.Survivors().CheckSurvivorExists(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if !exists {
+		return nil, domainError(db.ErrSurvivorNotFound)
+	}
+
+	history, err := handle.DB.Survivors().GetLocationHistory(id, fromTime, toTime)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if history == nil {
+		history = []models.LocationHistory{}
+	}
+	if format != "geojson" {
+		return history, nil
+	}
+
+	coordinates := make([][]float64, 0, len(history))
+	timestamps := make([]time.Time, 0, len(history))
+	for _, entry := range history {
+		coordinates = append(coordinates, []float64{float64(entry.Location.Longitude), float64(entry.Location.Latitude)})
+		timestamps = append(timestamps, entry.Timestamp)
+	}
+	return models.GeoJSONFeatureObject{
+		Type: models.GeoJSONFeature,
+		Geometry: models.GeoJSONGeometry{
+			Type:        models.GeoJSONLineString,
+			Coordinates: coordinates,
+		},
+		Properties: map[string]interface{}{
+			"survivor_id": id,
+			"timestamps":  timestamps,
+		},
+	}, nil
 }
 
 // new survivor handler
 // create new survivor entry to the database
-func (handle *Handler) UpdateSurvivorHandler(sr models.Survivor) error {
+// survivors can only update their own record. When the location is changed,
+// the recent robot sightings and the infected survivors within the alert
+// radius of the new location are returned and pushed as alerts
+func (handle *Handler) UpdateSurvivorHandler(principal models.Principal, sr models.Survivor) (*models.SurvivorUpdateResult, error) {
+	sr.ID = survivorOf(principal, sr.ID)
+	if err := authorizeSurvivor(principal, sr.ID); err != nil {
+		return nil, err
+	}
+	// replacing the inventory could add items
+	if sr.Resources != nil && principal.Role == models.RoleSurvivor {
+		return nil, Forbidden(CodeRoleNotAllowed, errors.New("survivors can't replace their inventory, use a trade"))
+	}
+	// validate the payload
+	if err := validation.SurvivorUpdate(sr); err != nil {
+		return nil, domainError(err)
+	}
+
 	//check user id already exists
 	exists, err := handle.DB.Survivors().CheckSurvivorExists(sr.ID)
 	if err != nil {
-		return fmt.Errorf("unable to process your request")
+		return nil, Internal(err)
 	}
 	if !exists {
-		return fmt.Errorf("survivor not exists in the system")
+		return nil, domainError(db.ErrSurvivorNotFound)
 	}
 
 	// update the survivor
-	return handle.DB.Survivors().Update(sr)
+	if err = handle.DB.Survivors().Update(sr); err != nil {
+		return nil, domainError(err)
+	}
+
+	result := &models.SurvivorUpdateResult{Alerts: []models.ProximityAlert{}}
+	if (sr.Location == models.Location{}) {
+		return result, nil
+	}
+	// the update is already stored, so the failed alert lookup is only logged
+	result.Alerts, err = handle.proximityAlerts(sr.ID, sr.Location)
+	if err != nil {
+		handle.Logger.Error("unable to check the surroundings of the survivor", zap.String("survivor_id", sr.ID), zap.Error(err))
+		result.Alerts = []models.ProximityAlert{}
+	}
+	for _, alert := range result.Alerts {
+		handle.Alerts.Publish(alert)
+	}
+	return result, nil
+}
+
+// recent robot sightings and infected survivors within the alert radius of
+// the location, closest first
+func (handle *Handler) proximityAlerts(survivorID string, location models.Location) ([]models.ProximityAlert, error) {
+	now := time.Now().UTC()
+	latitude, longitude := float64(location.Latitude), float64(location.Longitude)
+
+	alerts := []models.ProximityAlert{}
+	sightings, err := handle.DB.Sightings().List(models.SightingQuery{
+		Latitude:  &latitude,
+		Longitude: &longitude,
+		Radius:    handle.Alerts.Radius(),
+		SeenFrom:  now.Add(-handle.Alerts.Window()),
+		Limit:     maxPageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range sightings {
+		alerts = append(alerts, models.ProximityAlert{
+			Kind:       models.AlertRobotSighting,
+			SurvivorID: survivorID,
+			Distance:   db.Distance(location, sightings[i].Location),
+			Location:   sightings[i].Location,
+			Sighting:   &sightings[i],
+			CreatedAt:  now,
+		})
+	}
+
+	infected, err := handle.DB.Survivors().Nearby(models.NearbyQuery{
+		Latitude:  &latitude,
+		Longitude: &longitude,
+		Radius:    handle.Alerts.Radius(),
+		Status:    "infected",
+		Limit:     maxPageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, nearby := range infected {
+		if nearby.ID == survivorID {
+			continue
+		}
+		alerts = append(alerts, models.ProximityAlert{
+			Kind:               models.AlertInfectedSurvivor,
+			SurvivorID:         survivorID,
+			Distance:           nearby.Distance,
+			Location:           nearby.Location,
+			InfectedSurvivorID: nearby.ID,
+			CreatedAt:          now,
+		})
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].Distance < alerts[j].Distance
+	})
+	return alerts, nil
+}
+
+// push the new sighting to the non infected survivors within the alert
+// radius, the failures are only logged as the sighting is already stored
+func (handle *Handler) alertSurvivorsNear(sighting models.RobotSighting) {
+	if sighting.SeenAt.Before(time.Now().Add(-handle.Alerts.Window())) {
+		return
+	}
+	latitude, longitude := float64(sighting.Location.Latitude), float64(sighting.Location.Longitude)
+	survivors, err := handle.DB.Survivors().Nearby(models.NearbyQuery{
+		Latitude:  &latitude,
+		Longitude: &longitude,
+		Radius:    handle.Alerts.Radius(),
+		Status:    "non-infected",
+		Limit:     maxPageSize,
+	})
+	if err != nil {
+		handle.Logger.Error("unable to find the survivors near the sighting", zap.String("sighting_id", sighting.ID), zap.Error(err))
+		return
+	}
+	now := time.Now().UTC()
+	for _, nearby := range survivors {
+		// the reporter already knows about the sighting
+		if nearby.ID == sighting.ReportedBy {
+			continue
+		}
+		handle.Alerts.Publish(models.ProximityAlert{
+			Kind:       models.AlertRobotSighting,
+			SurvivorID: nearby.ID,
+			Distance:   nearby.Distance,
+			Location:   sighting.Location,
+			Sighting:   &sighting,
+			CreatedAt:  now,
+		})
+	}
+}
+
+// subscribe to the alerts of the survivor
+// survivors can only subscribe to their own alerts
+func (handle *Handler) SubscribeAlertsHandler(principal models.Principal, id string) (<-chan models.ProximityAlert, func(), error) {
+	if err := authorizeSurvivor(principal, id); err != nil {
+		return nil, nil, err
+	}
+	exists, err := handle.DB.Survivors().CheckSurvivorExists(id)
+	if err != nil {
+		return nil, nil, Internal(err)
+	}
+	if !exists {
+		return nil, nil, domainError(db.ErrSurvivorNotFound)
+	}
+	alerts, unsubscribe := handle.Alerts.Subscribe(id)
+	return alerts, unsubscribe, nil
+}
+
+// survivor inventory
+// fetch the current inventory of the survivor
+func (handle *Handler) InventoryHandler(id string) (models.Resources, error) {
+	survivor, err := handle.DB.Survivors().GetSurvivor(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if survivor == nil {
+		return nil, domainError(db.ErrSurvivorNotFound)
+	}
+	if survivor.Resources == nil {
+		return models.Resources{}, nil
+	}
+	return survivor.Resources, nil
+}
+
+// adjust survivor inventory
+// add or remove items from the survivor inventory
+func (handle *Handler) AdjustInventoryHandler(principal models.Principal, id string, adjustment models.InventoryAdjustment) (models.Resources, error) {
+	if err := authorizeSurvivor(principal, id); err != nil {
+		return nil, err
+	}
+	if err := authorizeInventoryChange(principal, adjustment.Items); err != nil {
+		return nil, err
+	}
+	if err := validation.InventoryAdjustment(adjustment); err != nil {
+		return nil, domainError(err)
+	}
+
+	inventory, err := handle.DB.Survivors().AdjustInventory(id, adjustment.Items)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return inventory, nil
+}
+
+// redistribute locked inventory
+// move the inventory of an infected survivor to the shelter pool
+func (handle *Handler) RedistributeInventoryHandler(principal models.Principal, id string) (*models.Redistribution, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	moved, err := handle.DB.Survivors().RedistributeInventory(id)
+	if err != nil {
+		return nil, domainError(err)
+	}
+
+	shelter, err := handle.DB.Shelter().Inventory()
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return &models.Redistribution{
+		SurvivorID: id,
+		Moved:      moved,
+		Shelter:    shelter,
+	}, nil
+}
+
+// shelter pool inventory
+func (handle *Handler) ShelterInventoryHandler(principal models.Principal) (models.Resources, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	shelter, err := handle.DB.Shelter().Inventory()
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return shelter, nil
+}
+
+// propose a trade to another survivor
+// both sides of the trade should have the same points, survivors can only
+// offer their own items. The items are swapped when the other survivor
+// accepts the proposal
+func (handle *Handler) TradeHandler(principal models.Principal, trade models.Trade) (*models.TradeProposal, error) {
+	trade.From.ID = survivorOf(principal, trade.From.ID)
+	if err := authorizeSurvivor(principal, trade.From.ID); err != nil {
+		return nil, err
+	}
+	if err := validation.Trade(trade); err != nil {
+		return nil, domainError(err)
+	}
+	if fromPoints, toPoints := tradePoints(trade.From.Items), tradePoints(trade.To.Items); fromPoints != toPoints {
+		return nil, &Error{
+			Kind:    KindValidation,
+			Code:    CodeTradeUnbalanced,
+			Message: fmt.Sprintf("trade points doesn't match, %d against %d", fromPoints, toPoints),
+		}
+	}
+	for _, id := range []string{trade.From.ID, trade.To.ID} {
+		exists, err := handle.DB.Survivors().CheckSurvivorExists(id)
+		if err != nil {
+			return nil, Internal(err)
+		}
+		if !exists {
+			return nil, domainError(db.ErrSurvivorNotFound)
+		}
+	}
+
+	proposal := models.TradeProposal{
+		ID:        primitive.NewObjectID().Hex(),
+		From:      trade.From,
+		To:        trade.To,
+		Status:    models.TradePending,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := handle.DB.Trades().New(proposal)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return &proposal, nil
+}
+
+// list the trade proposals from and to the survivor
+func (handle *Handler) ListTradesHandler(principal models.Principal, id string, status string) ([]models.TradeProposal, error) {
+	if err := authorizeSurvivor(principal, id); err != nil {
+		return nil, err
+	}
+	switch models.TradeStatus(status) {
+	case "", models.TradePending, models.TradeAccepted, models.TradeDeclined:
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid trade status %v", status)
+	}
+
+	trades, err := handle.DB.Trades().List(id, models.TradeStatus(status))
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return trades, nil
+}
+
+// accept or decline a trade proposal
+// only the receiving survivor can accept the proposal, both survivors can
+// decline it. Infected survivors are not allowed to trade
+func (handle *Handler) ResolveTradeHandler(principal models.Principal, id string, decision models.TradeDecision) (*models.TradeProposal, error) {
+	if err := validation.TradeDecision(decision); err != nil {
+		return nil, domainError(err)
+	}
+	proposal, err := handle.DB.Trades().Get(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if proposal == nil {
+		return nil, domainError(db.ErrTradeNotFound)
+	}
+
+	status := models.TradeAccepted
+	err = authorizeSurvivor(principal, proposal.To.ID)
+	if decision.Decision == "decline" {
+		status = models.TradeDeclined
+		if err != nil {
+			err = authorizeSurvivor(principal, proposal.From.ID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the accepted proposals swap the items
+	resolved, err := handle.DB.Trades().Resolve(id, status)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return resolved, nil
+}
+
+// total points of the items
+func tradePoints(items models.Resources) int {
+	points := 0
+	for item, quantity := range items {
+		points += models.ResourcePoints[item] * quantity
+	}
+	return points
 }
 
 // mark a survivor as infected
-func (handle *Handler) MarkSurvivorInfectedHandler(sr models.SurvivorInfected) error {
+// survivors can only report as themselves, the field officers and the admins
+// can file the reports on behalf of the survivors
+func (handle *Handler) MarkSurvivorInfectedHandler(principal models.Principal, sr models.SurvivorInfected) error {
+	sr.ReportedBy = survivorOf(principal, sr.ReportedBy)
+	if err := authorizeSurvivor(principal, sr.ReportedBy); err != nil {
+		return err
+	}
+	if err := validation.SurvivorInfected(sr); err != nil {
+		return domainError(err)
+	}
+
 	//check user id already exists
 	exists, err := handle.DB.Survivors().CheckSurvivorExists(sr.ID)
 	if err != nil {
-		return fmt.Errorf("unable to process your request")
+		return Internal(err)
+	}
+	if !exists {
+		return domainError(db.ErrSurvivorNotFound)
+	}
+
+	// only the known survivors can report
+	exists, err = handle.DB.Survivors().CheckSurvivorExists(sr.ReportedBy)
+	if err != nil {
+		return Internal(err)
+	}
+	if !exists {
+		return NotFound(CodeReporterNotFound, errors.New("unable to identify the reporter"))
+	}
+
+	// mark the survivor as infetcted with the report details
+	err = handle.DB.Survivors().Infected(models.InfectionReportRecord{
+		SurvivorID: sr.ID,
+		ReportedBy: sr.ReportedBy,
+		Reason:     sr.Reason,
+		Location:   sr.Location,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return domainError(err)
+	}
+	return nil
+}
+
+// infection reports against the survivor
+func (handle *Handler) InfectionReportsHandler(id string) ([]models.InfectionReportRecord, error) {
+	exists, err := handle.DB.Survivors().CheckSurvivorExists(id)
+	if err != nil {
+		return nil, Internal(err)
 	}
 	if !exists {
-		return fmt.Errorf("unable to identify the survivor")
+		return nil, domainError(db.ErrSurvivorNotFound)
 	}
 
-	// mark the survivor as infetcted
-	return handle.DB.Survivors().Infected(sr.ID, sr.ReportedBy)
+	reports, err := handle.DB.Reports().List(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if reports == nil {
+		reports = []models.InfectionReportRecord{}
+	}
+	return reports, nil
+}
+
+// retract an infection report
+// the reporter withdraws the infection report against the survivor
+func (handle *Handler) RetractInfectionReportHandler(principal models.Principal, sr models.SurvivorInfected) error {
+	sr.ReportedBy = survivorOf(principal, sr.ReportedBy)
+	if err := authorizeSurvivor(principal, sr.ReportedBy); err != nil {
+		return err
+	}
+	if err := validation.InfectionRetraction(sr); err != nil {
+		return domainError(err)
+	}
+
+	err := handle.DB.Survivors().RetractReport(sr.ID, sr.ReportedBy)
+	if err != nil {
+		return domainError(err)
+	}
+	return nil
+}
+
+// new infection appeal
+// a reported survivor can appeal against the infection reports, only one
+// appeal can wait for the decision at a time
+func (handle *Handler) NewAppealHandler(principal models.Principal, id string, reason string) (*models.Appeal, error) {
+	if err := authorizeSurvivor(principal, id); err != nil {
+		return nil, err
+	}
+	if err := validation.Appeal(models.Appeal{Reason: reason}); err != nil {
+		return nil, domainError(err)
+	}
+
+	survivor, err := handle.DB.Survivors().GetSurvivor(id)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if survivor == nil {
+		return nil, domainError(db.ErrSurvivorNotFound)
+	}
+	if survivor.ReportedCount == 0 {
+		return nil, Conflict(CodeNothingToAppeal, errors.New("survivor has no infection reports to appeal"))
+	}
+
+	appeal := models.Appeal{
+		ID:         primitive.NewObjectID().Hex(),
+		SurvivorID: id,
+		Reason:     reason,
+		Status:     models.AppealPending,
+		CreatedAt:  time.Now().UTC(),
+	}
+	err = handle.DB.Appeals().New(appeal)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return &appeal, nil
+}
+
+// list infection appeals
+func (handle *Handler) ListAppealsHandler(principal models.Principal, status string) ([]models.Appeal, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	switch models.AppealStatus(status) {
+	case "", models.AppealPending, models.AppealUpheld, models.AppealDismissed:
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid appeal status %v", status)
+	}
+
+	appeals, err := handle.DB.Appeals().List(models.AppealStatus(status))
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if appeals == nil {
+		appeals = []models.Appeal{}
+	}
+	return appeals, nil
+}
+
+// resolve infection appeal
+// when the appeal is upheld, all the infection reports of the survivor are
+// removed and the reported count is recomputed
+func (handle *Handler) ResolveAppealHandler(principal models.Principal, id string, decision models.AppealDecision) (*models.Appeal, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := validation.AppealDecision(decision); err != nil {
+		return nil, domainError(err)
+	}
+	status := models.AppealDismissed
+	if decision.Decision == "uphold" {
+		status = models.AppealUpheld
+	}
+
+	// the upheld appeals clear the infection reports
+	appeal, err := handle.DB.Appeals().Resolve(id, status)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return appeal, nil
 }
 
 // infected/ non infected percentage
 func (handle *Handler) InfectionPercentagehandler() (*models.InfectionReport, error) {
 	infectedCount, err := handle.DB.Survivors().InfectedCount()
 	if err != nil {
-		return nil, fmt.Errorf("unable to process your request")
+		return nil, Internal(err)
 	}
 	totalSurvivors, err := handle.DB.Survivors().TotalSurvivors()
 	if err != nil {
-		return nil, fmt.Errorf("unable to process your request")
+		return nil, Internal(err)
+	}
+	// no survivors yet, avoid the division by zero
+	if totalSurvivors == 0 {
+		return &models.InfectionReport{}, nil
 	}
 
 	return &models.InfectionReport{
@@ -90,30 +1014,433 @@ func (handle *Handler) InfectionPercentagehandler() (*models.InfectionReport, er
 // list our infected or non infected survivors list
 func (handle *Handler) InfectionNonInfectionListhandler(criteria string) ([]models.Survivor, error) {
 	if criteria != "infected" && criteria != "non-infected" {
-		return nil, fmt.Errorf("invalid url %v", criteria)
+		return nil, BadRequest(CodeBadRequest, "invalid url %v", criteria)
 	}
 	data, err := handle.DB.Survivors().GetSurvivors(criteria)
 	if err != nil {
-		return nil, fmt.Errorf("unable to process your request")
+		return nil, Internal(err)
+	}
+	if data == nil {
+		data = []models.Survivor{}
 	}
 
 	return data, nil
 }
 
-// list our infected or non infected survivors list
-func (handle *Handler) LoadRobotsHandler(robotList []models.RobotList) error {
-	err := handle.DB.Robots().LoadData(robotList)
+// load robots handler
+// only the admins can reload the robots
+// the robots are fetched from the configured source, the source failures
+// are bad gateway errors
+func (handle *Handler) LoadRobotsHandler(principal models.Principal) (*models.RobotLoad, error) {
+	if err := authorizeRole(principal, models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	feed, err := handle.Robots.Fetch(context.TODO())
+	if err != nil {
+		return nil, BadGateway(CodeRobotSourceUnavailable, err)
+	}
+	robots, rejected := parseRobots(feed.Robots)
+	if len(robots) == 0 {
+		return nil, BadGateway(CodeRobotFeedEmpty, fmt.Errorf("%s has no valid robots", feed.Source))
+	}
+
+	err = handle.DB.Robots().LoadData(robots)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return &models.RobotLoad{
+		Source:   feed.Source,
+		Loaded:   len(robots),
+		Rejected: rejected,
+	}, nil
+}
+
+// validate the feed entries and parse the manufactured dates, the invalid
+// entries are rejected and the last entry wins when the feed repeats a
+// serial number
+func parseRobots(feed []models.RobotList) ([]models.RobotList, []models.RobotRejection) {
+	rejected := []models.RobotRejection{}
+	index := make(map[string]int, len(feed))
+	robots := make([]models.RobotList, 0, len(feed))
+	for i, robot := range feed {
+		var fieldErrors validation.Errors
+		if errors.As(validation.Robot(robot), &fieldErrors) {
+			rejected = append(rejected, models.RobotRejection{
+				Index:        i,
+				SerialNumber: robot.SerialNumber,
+				Errors:       fieldErrors,
+			})
+			continue
+		}
+		robot.ManufacturedAt, _ = models.ParseRobotDate(robot.ManufacturedDate)
+		if i, ok := index[robot.SerialNumber]; ok {
+			robots[i] = robot
+			continue
+		}
+		index[robot.SerialNumber] = len(robots)
+		robots = append(robots, robot)
+	}
+	return robots, rejected
+}
+
+// same robot details
+func sameRobot(a models.RobotList, b models.RobotList) bool {
+	return a.Model == b.Model &&
+		a.SerialNumber == b.SerialNumber &&
+		a.ManufacturedDate == b.ManufacturedDate &&
+		a.ManufacturedAt.Equal(b.ManufacturedAt) &&
+		a.Category == b.Category
+}
+
+// list robots handler
+// filtered, sorted and paginated list of the robots
+func (handle *Handler) ListRobotsHandler(query models.RobotQuery) (*models.RobotPage, error) {
+	if query.Category != "" && !validation.IsRobotCategory(query.Category) {
+		return nil, BadRequest(CodeBadRequest, "invalid category %v", query.Category)
+	}
+	var err error
+	if query.ManufacturedFrom, err = robotDate(query.From, false); err != nil {
+		return nil, BadRequest(CodeBadRequest, "invalid from date %v", query.From)
+	}
+	if query.ManufacturedTo, err = robotDate(query.To, true); err != nil {
+		return nil, BadRequest(CodeBadRequest, "invalid to date %v", query.To)
+	}
+	if !query.ManufacturedFrom.IsZero() && !query.ManufacturedTo.IsZero() && query.ManufacturedFrom.After(query.ManufacturedTo) {
+		return nil, BadRequest(CodeBadRequest, "invalid date range")
+	}
+	switch query.SortBy {
+	case "":
+		query.SortBy = "serial"
+	case "serial", "model", "manufactured":
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid sort field %v", query.SortBy)
+	}
+	switch query.Order {
+	case "":
+		query.Order = "asc"
+	case "asc", "desc":
+	default:
+		return nil, BadRequest(CodeBadRequest, "invalid sort order %v", query.Order)
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultPageSize
+	case query.Limit < 0 || query.Limit > maxPageSize:
+		return nil, BadRequest(CodeBadRequest, "limit should be between 1 and %d", maxPageSize)
+	}
+
+	page, err := handle.DB.Robots().ListRobots(query)
+	if err != nil {
+		return nil, domainError(err)
+	}
+	return page, nil
+}
+
+// parse the date (2006-01-02) or the time (RFC3339) of the query, a date
+// only end of the range covers the whole day
+func robotDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if end {
+			date = date.Add(24*time.Hour - time.Millisecond)
+		}
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		return fmt.Errorf("unable to process your request")
+		return time.Time{}, err
+	}
+	return date.UTC(), nil
+}
+
+// sync log entries returned by the sync status
+const syncHistorySize = 10
+
+// sync the stored robots with the robot feed
+// the feed is diffed against the stored robots by the serial number, the
+// robots missing from the feed are removed. Every sync is recorded in the
+// sync log, including the failed ones
+func (handle *Handler) SyncRobotsHandler(ctx context.Context) (*models.RobotSyncLog, error) {
+	entry := models.RobotSyncLog{
+		ID:        primitive.NewObjectID().Hex(),
+		StartedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	err := handle.syncRobots(ctx, &entry)
+	entry.FinishedAt = time.Now().UTC().Truncate(time.Millisecond)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if logErr := handle.DB.Robots().NewSyncLog(entry); logErr != nil {
+		handle.Logger.Error("unable to store the robot sync log", zap.Error(logErr))
+	}
+	return &entry, err
+}
+
+// fetch the feed, diff and apply the changes
+func (handle *Handler) syncRobots(ctx context.Context, entry *models.RobotSyncLog) error {
+	feed, err := handle.Robots.Fetch(ctx)
+	if err != nil {
+		return BadGateway(CodeRobotSourceUnavailable, err)
+	}
+	entry.Source = feed.Source
+
+	stored, err := handle.DB.Robots().ListData()
+	if err != nil {
+		return Internal(err)
+	}
+	current := make(map[string]models.RobotList, len(stored))
+	for _, robot := range stored {
+		current[robot.SerialNumber] = robot
+	}
+
+	robots, rejected := parseRobots(feed.Robots)
+	entry.Rejected = len(rejected)
+	// an empty feed would remove every stored robot
+	if len(robots) == 0 {
+		return BadGateway(CodeRobotFeedEmpty, fmt.Errorf("%s has no valid robots", feed.Source))
+	}
+	incoming := make(map[string]bool, len(robots))
+
+	var upserts []models.RobotList
+	for _, robot := range robots {
+		incoming[robot.SerialNumber] = true
+		existing, ok := current[robot.SerialNumber]
+		switch {
+		case !ok:
+			entry.Inserted++
+			upserts = append(upserts, robot)
+		case !sameRobot(existing, robot):
+			entry.Updated++
+			upserts = append(upserts, robot)
+		default:
+			entry.Unchanged++
+		}
+	}
+	var removals []string
+	for _, robot := range stored {
+		if !incoming[robot.SerialNumber] {
+			removals = append(removals, robot.SerialNumber)
+		}
+	}
+	entry.Removed = len(removals)
+
+	if err = handle.DB.Robots().Sync(upserts, removals); err != nil {
+		return Internal(err)
 	}
 	return nil
 }
 
-// list our infected or non infected survivors list
-func (handle *Handler) ListRobotsHandler() ([]models.RobotList, error) {
-	robotList, err := handle.DB.Robots().ListData()
+// robot report handler
+// composition of the robots
+func (handle *Handler) RobotReportHandler() (*models.RobotReport, error) {
+	report, err := handle.DB.Robots().Report()
+	if err != nil {
+		return nil, Internal(err)
+	}
+	return report, nil
+}
+
+// report robot sighting handler
+// the sightings with a serial number should match a robot of the robot list,
+// the model and the category are taken from the robot list
+func (handle *Handler) NewSightingHandler(principal models.Principal, sighting models.RobotSighting) (*models.RobotSighting, error) {
+	sighting.ReportedBy = survivorOf(principal, sighting.ReportedBy)
+	if err := authorizeSurvivor(principal, sighting.ReportedBy); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if sighting.SeenAt.IsZero() {
+		sighting.SeenAt = now
+	}
+	sighting.SeenAt = sighting.SeenAt.UTC().Truncate(time.Millisecond)
+	if sighting.Count == 0 {
+		sighting.Count = 1
+	}
+	if err := validation.Sighting(sighting); err != nil {
+		return nil, domainError(err)
+	}
+
+	// only the known survivors can report
+	exists, err := handle.DB.Survivors().CheckSurvivorExists(sighting.ReportedBy)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if !exists {
+		return nil, NotFound(CodeReporterNotFound, errors.New("unable to identify the reporter"))
+	}
+
+	if sighting.SerialNumber != "" {
+		robot, err := handle.DB.Robots().GetRobot(sighting.SerialNumber)
+		if err != nil {
+			return nil, Internal(err)
+		}
+		if robot == nil {
+			return nil, domainError(db.ErrRobotNotFound)
+		}
+		sighting.Model = robot.Model
+		sighting.Category = robot.Category
+	}
+
+	sighting.ID = primitive.NewObjectID().Hex()
+	sighting.CreatedAt = now
+	if err = handle.DB.Sightings().New(sighting); err != nil {
+		return nil, Internal(err)
+	}
+	handle.alertSurvivorsNear(sighting)
+	return &sighting, nil
+}
+
+// list robot sightings handler
+// sightings within the area and the time window, the latest first
+func (handle *Handler) ListSightingsHandler(query models.SightingQuery) ([]models.RobotSighting, error) {
+	if (query.Latitude == nil) != (query.Longitude == nil) {
+		return nil, BadRequest(CodeBadRequest, "lat and lon should be used together")
+	}
+	if query.Latitude != nil {
+		if *query.Latitude < -90 || *query.Latitude > 90 || *query.Longitude < -180 || *query.Longitude > 180 {
+			return nil, BadRequest(CodeBadRequest, "invalid lat or lon")
+		}
+		if query.Radius <= 0 {
+			return nil, BadRequest(CodeBadRequest, "radius should be greater than zero")
+		}
+	}
+	var err error
+	if query.From != "" {
+		if query.SeenFrom, err = time.Parse(time.RFC3339, query.From); err != nil {
+			return nil, BadRequest(CodeBadRequest, "invalid from time, should be RFC3339")
+		}
+	}
+	if query.To != "" {
+		if query.SeenTo, err = time.Parse(time.RFC3339, query.To); err != nil {
+			return nil, BadRequest(CodeBadRequest, "invalid to time, should be RFC3339")
+		}
+	}
+	if query.Category != "" && !validation.IsRobotCategory(query.Category) {
+		return nil, BadRequest(CodeBadRequest, "invalid category %v", query.Category)
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultPageSize
+	case query.Limit < 0 || query.Limit > maxPageSize:
+		return nil, BadRequest(CodeBadRequest, "limit should be between 1 and %d", maxPageSize)
+	}
+
+	sightings, err := handle.DB.Sightings().List(query)
 	if err != nil {
-		return nil, fmt.Errorf("unable to process your request")
+		return nil, Internal(err)
+	}
+	return sightings, nil
+}
+
+// threat map handler
+// robot sightings of the time window bucketed into the grid cells, as the
+// list of the cells or as a GeoJSON FeatureCollection of the cell polygons.
+// The last 24 hours are used when the time window is not given
+func (handle *Handler) ThreatMapHandler(query models.ThreatMapQuery) (interface{}, error) {
+	switch {
+	case query.CellSize == 0:
+		query.CellSize = defaultThreatCellSize
+	case query.CellSize < minThreatCellSize || query.CellSize > maxThreatCellSize:
+		return nil, BadRequest(CodeBadRequest, "cell should be between %v and %v degrees", minThreatCellSize, maxThreatCellSize)
+	}
+	bounds := []*float64{query.MinLatitude, query.MinLongitude, query.MaxLatitude, query.MaxLongitude}
+	given := 0
+	for _, bound := range bounds {
+		if bound != nil {
+			given++
+		}
+	}
+	switch given {
+	case 0:
+	case len(bounds):
+		if *query.MinLatitude < -90 || *query.MaxLatitude > 90 || *query.MinLongitude < -180 || *query.MaxLongitude > 180 ||
+			*query.MinLatitude > *query.MaxLatitude || *query.MinLongitude > *query.MaxLongitude {
+			return nil, BadRequest(CodeBadRequest, "invalid bounding box")
+		}
+	default:
+		return nil, BadRequest(CodeBadRequest, "min_lat, min_lon, max_lat and max_lon should be used together")
+	}
+	var err error
+	query.SeenTo = time.Now().UTC()
+	if query.To != "" {
+		if query.SeenTo, err = time.Parse(time.RFC3339, query.To); err != nil {
+			return nil, BadRequest(CodeBadRequest, "invalid to time, should be RFC3339")
+		}
+	}
+	query.SeenFrom = query.SeenTo.Add(-defaultThreatMapWindow)
+	if query.From != "" {
+		if query.SeenFrom, err = time.Parse(time.RFC3339, query.From); err != nil {
+			return nil, BadRequest(CodeBadRequest, "invalid from time, should be RFC3339")
+		}
+	}
+	if query.SeenFrom.After(query.SeenTo) {
+		return nil, BadRequest(CodeBadRequest, "invalid time window")
+	}
+	if query.Category != "" && !validation.IsRobotCategory(query.Category) {
+		return nil, BadRequest(CodeBadRequest, "invalid category %v", query.Category)
+	}
+	if query.Format != "" && query.Format != "json" && query.Format != "geojson" {
+		return nil, BadRequest(CodeBadRequest, "invalid format %v", query.Format)
+	}
+
+	cells, err := handle.DB.Sightings().ThreatMap(query)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	if query.Format != "geojson" {
+		return models.ThreatMap{
+			CellSize: query.CellSize,
+			From:     query.SeenFrom,
+			To:       query.SeenTo,
+			Cells:    cells,
+		}, nil
+	}
+
+	features := make([]models.GeoJSONFeatureObject, 0, len(cells))
+	for _, cell := range cells {
+		features = append(features, models.GeoJSONFeatureObject{
+			Type: models.GeoJSONFeature,
+			Geometry: models.GeoJSONGeometry{
+				Type: models.GeoJSONPolygon,
+				Coordinates: [][][]float64{{
+					{cell.MinLongitude, cell.MinLatitude},
+					{cell.MaxLongitude, cell.MinLatitude},
+					{cell.MaxLongitude, cell.MaxLatitude},
+					{cell.MinLongitude, cell.MaxLatitude},
+					{cell.MinLongitude, cell.MinLatitude},
+				}},
+			},
+			Properties: map[string]interface{}{
+				"row":       cell.Row,
+				"column":    cell.Column,
+				"sightings": cell.Sightings,
+				"robots":    cell.Robots,
+			},
+		})
+	}
+	return models.GeoJSONFeatureCollectionObject{
+		Type:     models.GeoJSONFeatureCollection,
+		Features: features,
+	}, nil
+}
+
+// status of the background robot sync
+func (handle *Handler) RobotSyncStatusHandler(interval time.Duration) (*models.RobotSyncStatus, error) {
+	history, err := handle.DB.Robots().SyncLogs(syncHistorySize)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	status := &models.RobotSyncStatus{
+		Enabled: interval > 0,
+		History: history,
+	}
+	if status.History == nil {
+		status.History = []models.RobotSyncLog{}
+	}
+	if interval > 0 {
+		status.Interval = interval.String()
 	}
-	return robotList, nil
+	return status, nil
 }
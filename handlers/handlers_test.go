@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"robot-apocalypse/pkg/alerts"
+	"robot-apocalypse/pkg/auth"
+	"robot-apocalypse/pkg/db"
+	"robot-apocalypse/pkg/models"
+	"robot-apocalypse/pkg/robotsource"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var admin = models.Principal{ID: "admin", Role: models.RoleAdmin, Scopes: []models.Scope{models.ScopeAdmin}}
+
+// handler on the memory storage
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	broker := alerts.NewBroker(1000, time.Hour)
+	t.Cleanup(broker.Close)
+	return NewHandler(zap.NewNop(), db.NewMemoryAdapter(), auth.NewIssuer("secret", time.Minute, time.Hour), nil, broker)
+}
+
+// register the survivor at the location
+func newTestSurvivor(t *testing.T, handle *Handler, id string, password string, latitude float32, longitude float32) {
+	t.Helper()
+	_, err := handle.NewSurvivorHandler(admin, models.Survivor{
+		ID:       id,
+		Name:     "survivor " + id,
+		Age:      30,
+		Password: password,
+		Location: models.Location{Latitude: latitude, Longitude: longitude},
+	})
+	if err != nil {
+		t.Fatalf("unable to add survivor %s: %v", id, err)
+	}
+}
+
+// status code of the handler error
+func statusOf(err error) int {
+	var handlerError *Error
+	if errors.As(err, &handlerError) {
+		return handlerError.StatusCode()
+	}
+	return 0
+}
+
+func TestRefreshTokenSingleUse(t *testing.T) {
+	handle := newTestHandler(t)
+	newTestSurvivor(t, handle, "srv1", "correct horse battery", 10, 10)
+
+	if _, err := handle.LoginHandler(models.Login{ID: "srv1", Password: "wrong horse battery"}); statusOf(err) != 401 {
+		t.Fatalf("login with a wrong password: error = %v, want 401", err)
+	}
+	tokens, err := handle.LoginHandler(models.Login{ID: "srv1", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	refreshed, err := handle.RefreshHandler(models.Refresh{RefreshToken: tokens.RefreshToken})
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Error("refresh returned the same refresh token")
+	}
+	// the used token is rejected, the new one works once
+	if _, err := handle.RefreshHandler(models.Refresh{RefreshToken: tokens.RefreshToken}); statusOf(err) != 401 {
+		t.Errorf("second use of the refresh token: error = %v, want 401", err)
+	}
+	if _, err := handle.RefreshHandler(models.Refresh{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Errorf("refresh with the new token failed: %v", err)
+	}
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	handle := newTestHandler(t)
+	newTestSurvivor(t, handle, "srv1", "correct horse battery", 10, 10)
+
+	tokens, err := handle.LoginHandler(models.Login{ID: "srv1", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if err := handle.LogoutHandler(models.Refresh{RefreshToken: tokens.RefreshToken}); err != nil {
+		t.Fatalf("logout failed: %v", err)
+	}
+	if _, err := handle.RefreshHandler(models.Refresh{RefreshToken: tokens.RefreshToken}); statusOf(err) != 401 {
+		t.Errorf("refresh after logout: error = %v, want 401", err)
+	}
+}
+
+// robot feed of the test, the next fetch returns the first entry
+type testFeed [][]models.RobotList
+
+func (feed *testFeed) Fetch(ctx context.Context) (*robotsource.Feed, error) {
+	if len(*feed) == 0 {
+		return nil, errors.New("feed is not reachable")
+	}
+	robots := (*feed)[0]
+	*feed = (*feed)[1:]
+	return &robotsource.Feed{Source: "test", Robots: robots}, nil
+}
+
+// valid robot of the feed
+func newTestRobot(serialNumber string, model string) models.RobotList {
+	return models.RobotList{
+		SerialNumber:     serialNumber,
+		Model:            model,
+		ManufacturedDate: "2021-08-02T13:05:00",
+		Category:         models.RobotCategoryLand,
+	}
+}
+
+func TestSyncRobots(t *testing.T) {
+	feed := &testFeed{
+		{newTestRobot("S1", "R1"), newTestRobot("S2", "R2"), newTestRobot("", "no serial")},
+		{newTestRobot("S1", "R1"), newTestRobot("S2", "R2-b"), newTestRobot("S3", "R3")},
+		{newTestRobot("S3", "R3")},
+		{newTestRobot("", "no serial"), {SerialNumber: "S4", Model: "R4", ManufacturedDate: "yesterday", Category: models.RobotCategoryLand}},
+	}
+	handle := newTestHandler(t)
+	handle.Robots = feed
+
+	want := []models.RobotSyncLog{
+		{Inserted: 2, Rejected: 1},
+		{Inserted: 1, Updated: 1, Unchanged: 1},
+		{Unchanged: 1, Removed: 2},
+	}
+	for i, counts := range want {
+		entry, err := handle.SyncRobotsHandler(context.Background())
+		if err != nil {
+			t.Fatalf("sync %d failed: %v", i+1, err)
+		}
+		if entry.Inserted != counts.Inserted || entry.Updated != counts.Updated || entry.Unchanged != counts.Unchanged ||
+			entry.Removed != counts.Removed || entry.Rejected != counts.Rejected {
+			t.Errorf("sync %d = %+v, want %+v", i+1, entry, counts)
+		}
+	}
+
+	// the feed without robots doesn't remove the stored ones
+	if _, err := handle.SyncRobotsHandler(context.Background()); statusOf(err) != 502 {
+		t.Errorf("sync of the empty feed: error = %v, want 502", err)
+	}
+	if robots, _ := handle.DB.Robots().ListData(); len(robots) != 1 {
+		t.Errorf("robots after the empty feed = %+v, want S3 kept", robots)
+	}
+
+	// the failed syncs are recorded in the log too
+	if _, err := handle.SyncRobotsHandler(context.Background()); statusOf(err) != 502 {
+		t.Errorf("sync of the unreachable feed: error = %v, want 502", err)
+	}
+	status, err := handle.RobotSyncStatusHandler(time.Minute)
+	if err != nil {
+		t.Fatalf("sync status failed: %v", err)
+	}
+	if len(status.History) != 5 || status.History[0].Error == "" || status.History[1].Error == "" {
+		t.Errorf("history = %+v, want the 5 syncs with the failed ones first", status.History)
+	}
+}
+
+func TestSightingAlertsSkipReporter(t *testing.T) {
+	handle := newTestHandler(t)
+	newTestSurvivor(t, handle, "reporter", "", 10, 10)
+	newTestSurvivor(t, handle, "nearby", "", 10.001, 10.001)
+	newTestSurvivor(t, handle, "far", "", 20, 20)
+
+	subscriptions := map[string]<-chan models.ProximityAlert{}
+	for _, id := range []string{"reporter", "nearby", "far"} {
+		alerts, unsubscribe := handle.Alerts.Subscribe(id)
+		defer unsubscribe()
+		subscriptions[id] = alerts
+	}
+
+	_, err := handle.NewSightingHandler(admin, models.RobotSighting{
+		ReportedBy: "reporter",
+		Category:   models.RobotCategoryLand,
+		Location:   models.Location{Latitude: 10, Longitude: 10},
+	})
+	if err != nil {
+		t.Fatalf("sighting failed: %v", err)
+	}
+
+	select {
+	case alert := <-subscriptions["nearby"]:
+		if alert.Kind != models.AlertRobotSighting {
+			t.Errorf("alert kind = %s, want %s", alert.Kind, models.AlertRobotSighting)
+		}
+	default:
+		t.Error("nearby survivor is not alerted")
+	}
+	for _, id := range []string{"reporter", "far"} {
+		select {
+		case alert := <-subscriptions[id]:
+			t.Errorf("%s is alerted: %+v", id, alert)
+		default:
+		}
+	}
+}
+
+func TestSightingAlertsSkipMissingLocations(t *testing.T) {
+	handle := newTestHandler(t)
+	newTestSurvivor(t, handle, "reporter", "", 10, 10)
+	newTestSurvivor(t, handle, "unknown", "", 0, 0)
+	alerts, unsubscribe := handle.Alerts.Subscribe("unknown")
+	defer unsubscribe()
+
+	_, err := handle.NewSightingHandler(admin, models.RobotSighting{
+		ReportedBy: "reporter",
+		Category:   models.RobotCategoryLand,
+		Location:   models.Location{Latitude: 0.001, Longitude: 0.001},
+	})
+	if err != nil {
+		t.Fatalf("sighting failed: %v", err)
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("survivor without a location is alerted: %+v", alert)
+	default:
+	}
+}
+
+func TestBootstrapKeyGetsTheAdminRole(t *testing.T) {
+	handle := newTestHandler(t)
+	// bootstrap key stored by the versions without the roles
+	hash := hashToken("admin-token")
+	err := handle.DB.APIKeys().New(models.APIKey{ID: "bootstrap-" + hash[:12], Hash: hash, Scopes: []models.Scope{models.ScopeAdmin}})
+	if err != nil {
+		t.Fatalf("unable to store the key: %v", err)
+	}
+
+	if err := handle.BootstrapAPIKeyHandler("admin-token"); err != nil {
+		t.Fatalf("bootstrap failed: %v", err)
+	}
+	principal, err := handle.AuthenticateHandler("admin-token")
+	if err != nil {
+		t.Fatalf("authentication failed: %v", err)
+	}
+	if principal.Role != models.RoleAdmin {
+		t.Errorf("role = %q, want %q", principal.Role, models.RoleAdmin)
+	}
+}
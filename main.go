@@ -2,41 +2,57 @@
 //
 // Golang Robot-Apocalypse API.
 //
-//     Schemes: http
-//     BasePath: /
-//     Version: 1.0.0
-//     Host: localhost:8080/api/v1
+//	Schemes: http
+//	BasePath: /
+//	Version: 1.0.0
+//	Host: localhost:8080/api/v1
 //
-//     Consumes:
-//     - application/json
+//	Consumes:
+//	- application/json
 //
-//     Produces:
-//     - application/json
+//	Produces:
+//	- application/json
 //
 // security:
 //   - APIKeyHeader: []
+//   - Bearer: []
 //
 // securityDefinitions:
-//  APIKeyHeader:
-//    type: apiKey
-//    in: header
-//    name: TOKEN
+//
+//	APIKeyHeader:
+//	  type: apiKey
+//	  in: header
+//	  name: TOKEN
+//	Bearer:
+//	  type: apiKey
+//	  in: header
+//	  name: Authorization
+//	  description: access token of the survivor login, sent as "Bearer {access_token}"
 //
 // swagger:meta
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"robot-apocalypse/handlers"
+	"robot-apocalypse/pkg/alerts"
+	"robot-apocalypse/pkg/auth"
 	"robot-apocalypse/pkg/db"
 	"robot-apocalypse/pkg/models"
+	"robot-apocalypse/pkg/robotsource"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/kelseyhightower/envconfig"
@@ -44,12 +60,22 @@ import (
 )
 
 var (
-	appName      = "robotApocalypse"         // application name
-	logger       *zap.Logger                 // zap logger
-	apiConfig    models.EnvironmentalConfigs // api environmental config
-	mongoAdapter *db.MongoAdapter            // mongo connection holder
+	appName   = "robotApocalypse"         // application name
+	logger    *zap.Logger                 // zap logger
+	apiConfig models.EnvironmentalConfigs // api environmental config
+	storage   db.Services                 // storage backend holder
+	tokens    *auth.Issuer                // survivor access token issuer
+	robots    robotsource.Source          // robots feed
+	broker    *alerts.Broker              // proximity alert broker
 )
 
+// comment sent on the idle alert streams, so the closed connections are
+// noticed
+const alertHeartbeat = 15 * time.Second
+
+// locals key of the authenticated principal
+const principalLocal = "principal"
+
 func main() {
 	// load the environmental configurations
 	err := envconfig.Process(appName, &apiConfig)
@@ -69,21 +95,76 @@ func main() {
 		defer logger.Sync()
 	}
 
-	// initiate mongo db connection
-	mongoAdapter, err = db.NewConnection(context.TODO(), apiConfig.MongoHost, apiConfig.MongoDatabase)
+	// initiate the storage backend
+	switch apiConfig.Storage {
+	case "memory":
+		logger.Info("using in-memory storage, data will not be persisted")
+		storage = db.NewMemoryAdapter()
+	case "mongo":
+		// initiate mongo db connection
+		storage, err = db.NewConnection(context.TODO(), apiConfig.MongoHost, apiConfig.MongoDatabase)
+		if err != nil {
+			logger.Error("unable to initialize database connection", zap.Error(err))
+			return
+		}
+	default:
+		logger.Error("unknown storage backend", zap.String("storage", apiConfig.Storage))
+		return
+	}
+	if err = storage.EnsureIndexes(); err != nil {
+		logger.Error("unable to create the database indexes", zap.Error(err))
+		return
+	}
+	// survivor access tokens
+	secret := apiConfig.JwtSecret
+	if secret == "" {
+		logger.Warn("jwt secret is not configured, the access tokens will be invalid after a restart")
+		random := make([]byte, 32)
+		if _, err = rand.Read(random); err != nil {
+			logger.Error("unable to generate the jwt secret", zap.Error(err))
+			return
+		}
+		secret = hex.EncodeToString(random)
+	}
+	tokens = auth.NewIssuer(secret, apiConfig.AccessTokenTTL, apiConfig.RefreshTokenTTL)
+
+	// robots feed
+	robots, err = robotsource.New(apiConfig)
 	if err != nil {
-		logger.Error("unable to initialize database connection", zap.Error(err))
+		logger.Error("unable to initialize the robot source", zap.Error(err))
 		return
 	}
 
+	// proximity alerts
+	broker = alerts.NewBroker(apiConfig.AlertRadius, apiConfig.AlertWindow)
+
+	// bootstrap admin api key, so the first api keys can be created
+	if apiConfig.AdminToken != "" {
+		if err = handlers.NewHandler(logger, storage, tokens, robots, broker).BootstrapAPIKeyHandler(apiConfig.AdminToken); err != nil {
+			logger.Error("unable to store the bootstrap api key", zap.Error(err))
+			return
+		}
+	}
+
 	// initiate fiber router
 	app := fiber.New(fiber.Config{
-		AppName: appName,
+		AppName:      appName,
+		ErrorHandler: errorHandler,
+		// params and query values are kept by the handlers (in-memory storage),
+		// so they should not point to the reused request buffers
+		Immutable: true,
 	})
 
 	// initiate routers and handlers
 	InitRouterhandlers(app)
 
+	// background robot sync, stopped on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if apiConfig.RobotSyncInterval > 0 {
+		go syncRobots(ctx, handlers.NewHandler(logger, storage, tokens, robots, broker), apiConfig.RobotSyncInterval)
+	}
+
 	// listener
 	logger.Info("...starting the server...")
 	go func() {
@@ -97,47 +178,294 @@ func main() {
 
 	<-c // This blocks the main thread until an interrupt is received
 	fmt.Println("Gracefully shutting down...")
+	cancel()
+	broker.Close()     // finish the alert streams, so the shutdown doesn't wait for them
 	_ = app.Shutdown() // shutdown
 }
 
+// write the alerts as server sent events until the subscription is closed or
+// the client is gone
+func streamAlerts(w *bufio.Writer, alerts <-chan models.ProximityAlert) {
+	heartbeat := time.NewTicker(alertHeartbeat)
+	defer heartbeat.Stop()
+
+	// send the headers right away
+	fmt.Fprint(w, ": connected\n\n")
+	if err := w.Flush(); err != nil {
+		return
+	}
+	for {
+		select {
+		case alert, ok := <-alerts:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(alert)
+			if err != nil {
+				logger.Error("unable to encode the alert", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "event: alert\ndata: %s\n\n", data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// sync the robots with the feed on start and on every tick, the failures
+// are logged and retried on the next tick
+func syncRobots(ctx context.Context, handler *handlers.Handler, interval time.Duration) {
+	syncRobotsOnce(ctx, handler)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			syncRobotsOnce(ctx, handler)
+		}
+	}
+}
+
+// sync the robots with the feed and log the result
+func syncRobotsOnce(ctx context.Context, handler *handlers.Handler) {
+	entry, err := handler.SyncRobotsHandler(ctx)
+	if err != nil {
+		logger.Error("robot sync failed", zap.Error(err))
+		return
+	}
+	logger.Info("robot sync finished",
+		zap.String("source", entry.Source),
+		zap.Int("inserted", entry.Inserted),
+		zap.Int("updated", entry.Updated),
+		zap.Int("removed", entry.Removed))
+}
+
 // InitRouterhandlers
 // This method id used to initiate all the api endpoints and its handler
 // methods
 func InitRouterhandlers(app *fiber.App) {
 	// initiate new api handler object
-	handler := handlers.NewHandler(logger, mongoAdapter)
+	handler := handlers.NewHandler(logger, storage, tokens, robots, broker)
 
 	// initiate a /api/v1 endpoint
 	v1 := app.Group("/api").Group("/v1")
 
+	// the auth endpoints are registered before the authentication middleware,
+	// so they can be used without a token
+
+	// survivor login
+	// swagger:route POST /auth/login Auth idOfLoginEndpoint
+	// exchange the survivor credentials with an access token and a refresh token
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/auth/login", func(c *fiber.Ctx) error {
+		var login models.Login
+
+		// parse the request body
+		if err := c.BodyParser(&login); err != nil {
+			return parseError(err)
+		}
+
+		tokenPair, err := handler.LoginHandler(login)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       tokenPair,
+		})
+	})
+
+	// refresh the access token
+	// swagger:route POST /auth/refresh Auth idOfRefreshEndpoint
+	// exchange the refresh token with a new token pair, the refresh token can be used once
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/auth/refresh", func(c *fiber.Ctx) error {
+		var refresh models.Refresh
+
+		// parse the request body
+		if err := c.BodyParser(&refresh); err != nil {
+			return parseError(err)
+		}
+
+		tokenPair, err := handler.RefreshHandler(refresh)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       tokenPair,
+		})
+	})
+
+	// survivor logout
+	// swagger:route POST /auth/logout Auth idOfLogoutEndpoint
+	// revoke the refresh token
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/auth/logout", func(c *fiber.Ctx) error {
+		var refresh models.Refresh
+
+		// parse the request body
+		if err := c.BodyParser(&refresh); err != nil {
+			return parseError(err)
+		}
+
+		if err := handler.LogoutHandler(refresh); err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully logged out",
+		})
+	})
+
+	// every other endpoint requires an api key or a survivor access token
+	v1.Use(authenticate(handler))
+
 	// swagger:route POST /survivors Survivors idOfSurvivorCreateEndpoint
 	// create new survivor endpoint
 	//
 	// responses:
 	//   200: APIResponseModel
-	v1.Post("/survivors", func(c *fiber.Ctx) error {
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/survivors", requireScope(models.ScopeSurvivorsWrite), idempotency(handler), func(c *fiber.Ctx) error {
 		var survivor models.Survivor
 
 		// parse the request body
 		if err := c.BodyParser(&survivor); err != nil {
-			logger.Error("unable to parse the request", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    "unable to parse the request",
-			})
+			return parseError(err)
 		}
 
-		err := handler.NewSurvivorHandler(survivor)
+		created, err := handler.NewSurvivorHandler(principal(c), survivor)
 		if err != nil {
-			logger.Error("unable to add new survivor", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
 			Message:    "successfully added survivor",
+			Data:       created,
+		})
+	})
+
+	// list survivors
+	// swagger:route GET /survivors Survivors idOfSurvivorListEndpoint
+	// filtered, sorted and paginated list of the survivors
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors", func(c *fiber.Ctx) error {
+		var query models.SurvivorQuery
+
+		// parse the query parameters
+		if err := c.QueryParser(&query); err != nil {
+			return parseError(err)
+		}
+
+		page, err := handler.ListSurvivorsHandler(query)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       page,
+		})
+	})
+
+	// nearby survivors
+	// registered before the /survivors/:id, so nearby is not taken as an id
+	// swagger:route GET /survivors/nearby Survivors idOfSurvivorNearbyEndpoint
+	// survivors within the radius of the point, closest first
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/nearby", func(c *fiber.Ctx) error {
+		var query models.NearbyQuery
+
+		// parse the query parameters
+		if err := c.QueryParser(&query); err != nil {
+			return parseError(err)
+		}
+
+		survivors, err := handler.NearbySurvivorsHandler(query)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       survivors,
+		})
+	})
+
+	// fetch survivor
+	// swagger:route GET /survivors/{id} Survivors idOfSurvivorFetchEndpoint
+	// fetch the survivor details
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   404: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/:id", func(c *fiber.Ctx) error {
+		survivor, err := handler.GetSurvivorHandler(c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       survivor,
+		})
+	})
+
+	// survivor location history
+	// swagger:route GET /survivors/{id}/locations Survivors idOfSurvivorLocationsEndpoint
+	// escape track of the survivor
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   404: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/:id/locations", func(c *fiber.Ctx) error {
+		track, err := handler.LocationHistoryHandler(c.Params("id"), c.Query("from"), c.Query("to"), c.Query("format"))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       track,
 		})
 	})
 
@@ -147,57 +475,220 @@ func InitRouterhandlers(app *fiber.App) {
 	//
 	// responses:
 	//   200: APIResponseModel
-	v1.Put("/survivors", func(c *fiber.Ctx) error {
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Put("/survivors", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
 		var survivor models.Survivor
 
 		// parse the request body
 		if err := c.BodyParser(&survivor); err != nil {
-			logger.Error("unable to parse the request", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    "unable to parse the request",
-			})
+			return parseError(err)
 		}
 
-		err := handler.UpdateSurvivorHandler(survivor)
+		result, err := handler.UpdateSurvivorHandler(principal(c), survivor)
 		if err != nil {
-			logger.Error("unable to update survivor", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
 			Message:    "successfully updated survivor",
+			Data:       result,
+		})
+	})
+
+	// survivor alert stream
+	// swagger:route GET /survivors/{id}/alerts Survivors idOfSurvivorAlertsEndpoint
+	// proximity alerts of the survivor as server sent events
+	//
+	// produces:
+	// - text/event-stream
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/:id/alerts", func(c *fiber.Ctx) error {
+		alerts, unsubscribe, err := handler.SubscribeAlertsHandler(principal(c), c.Params("id"))
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+			streamAlerts(w, alerts)
+		})
+		return nil
+	})
+
+	// survivor inventory
+	// swagger:route GET /survivors/{id}/inventory Survivors idOfSurvivorInventoryEndpoint
+	// fetch the survivor inventory
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   404: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/:id/inventory", func(c *fiber.Ctx) error {
+		inventory, err := handler.InventoryHandler(c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       inventory,
+		})
+	})
+
+	// adjust survivor inventory
+	// swagger:route PATCH /survivors/{id}/inventory Survivors idOfSurvivorInventoryAdjustEndpoint
+	// add or remove items from the survivor inventory
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Patch("/survivors/:id/inventory", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
+		var adjustment models.InventoryAdjustment
+
+		// parse the request body
+		if err := c.BodyParser(&adjustment); err != nil {
+			return parseError(err)
+		}
+
+		inventory, err := handler.AdjustInventoryHandler(principal(c), c.Params("id"), adjustment)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully updated the inventory",
+			Data:       inventory,
+		})
+	})
+
+	// propose a trade to another survivor
+	// swagger:route POST /trades Trades idOfTradeEndpoint
+	// propose an exchange of items to another survivor
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/trades", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
+		var trade models.Trade
+
+		// parse the request body
+		if err := c.BodyParser(&trade); err != nil {
+			return parseError(err)
+		}
+
+		proposal, err := handler.TradeHandler(principal(c), trade)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully proposed the trade",
+			Data:       proposal,
+		})
+	})
+
+	// accept or decline a trade proposal
+	// swagger:route PUT /trades/{id} Trades idOfTradeResolveEndpoint
+	// accept or decline the trade proposal, the items are swapped when the proposal is accepted
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Put("/trades/:id", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
+		var decision models.TradeDecision
+
+		// parse the request body
+		if err := c.BodyParser(&decision); err != nil {
+			return parseError(err)
+		}
+
+		proposal, err := handler.ResolveTradeHandler(principal(c), c.Params("id"), decision)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully resolved the trade",
+			Data:       proposal,
+		})
+	})
+
+	// trade proposals of the survivor
+	// swagger:route GET /survivors/{id}/trades Survivors idOfSurvivorTradesEndpoint
+	// list the trade proposals from and to the survivor
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/:id/trades", func(c *fiber.Ctx) error {
+		trades, err := handler.ListTradesHandler(principal(c), c.Params("id"), c.Query("status"))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       trades,
 		})
 	})
 
 	// mark the survivor as infected
-	// swagger:route Put /survivors/infected Survivors idOfSurvivorInfectedEndpoint
+	// swagger:route PUT /survivors/infected Survivors idOfSurvivorInfectedEndpoint
 	// mark the survivor as infected
 	//
 	// responses:
 	//   200: APIResponseModel
-	v1.Put("/survivors/infected", func(c *fiber.Ctx) error {
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Put("/survivors/infected", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
 		var survivor models.SurvivorInfected
 
 		// parse the request body
 		if err := c.BodyParser(&survivor); err != nil {
-			logger.Error("unable to parse the request", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    "unable to parse the request",
-			})
+			return parseError(err)
 		}
 
-		err := handler.MarkSurvivorInfectedHandler(survivor)
+		err := handler.MarkSurvivorInfectedHandler(principal(c), survivor)
 		if err != nil {
-			logger.Error("unable to update survivor", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
@@ -205,20 +696,154 @@ func InitRouterhandlers(app *fiber.App) {
 		})
 	})
 
+	// infection reports against the survivor
+	// swagger:route GET /survivors/{id}/reports Survivors idOfSurvivorReportsEndpoint
+	// list the infection reports against the survivor
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   404: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/survivors/:id/reports", func(c *fiber.Ctx) error {
+		reports, err := handler.InfectionReportsHandler(c.Params("id"))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       reports,
+		})
+	})
+
+	// retract the infection report
+	// swagger:route DELETE /survivors/infected Survivors idOfSurvivorInfectionRetractEndpoint
+	// retract the infection report of the reporter
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Delete("/survivors/infected", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
+		var survivor models.SurvivorInfected
+
+		// parse the request body
+		if err := c.BodyParser(&survivor); err != nil {
+			return parseError(err)
+		}
+
+		err := handler.RetractInfectionReportHandler(principal(c), survivor)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully retracted the infection report",
+		})
+	})
+
+	// appeal against the infection reports
+	// swagger:route POST /survivors/{id}/appeals Survivors idOfAppealCreateEndpoint
+	// file an appeal against the infection reports
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/survivors/:id/appeals", requireScope(models.ScopeSurvivorsWrite), func(c *fiber.Ctx) error {
+		var request models.Appeal
+
+		// parse the request body
+		if err := c.BodyParser(&request); err != nil {
+			return parseError(err)
+		}
+
+		appeal, err := handler.NewAppealHandler(principal(c), c.Params("id"), request.Reason)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully filed the appeal",
+			Data:       appeal,
+		})
+	})
+
 	// infected percentage
 	// swagger:route GET /report/percentage Report idOfreportPercentage
 	// Percentage
 	//
 	// responses:
 	//   200: APIResponseModel
-	v1.Get("/report/percentage", func(c *fiber.Ctx) error {
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/report/percentage", requireScope(models.ScopeReportsRead), func(c *fiber.Ctx) error {
 		reportData, err := handler.InfectionPercentagehandler()
 		if err != nil {
-			logger.Error("unable to fetch the survivor infection report", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       reportData,
+		})
+	})
+
+	// robot report
+	// registered before the /report/:criteria, so robots is not taken as a
+	// criteria
+	// swagger:route GET /report/robots Report idOfReportRobotsEndpoint
+	// robot counts by category, model and manufacture year with the oldest
+	// and the newest robots
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/report/robots", requireScope(models.ScopeReportsRead), func(c *fiber.Ctx) error {
+		reportData, err := handler.RobotReportHandler()
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       reportData,
+		})
+	})
+
+	// robot threat map
+	// registered before the /report/:criteria, so threat-map is not taken as
+	// a criteria
+	// swagger:route GET /report/threat-map Report idOfReportThreatMapEndpoint
+	// robot sightings of the time window bucketed into the grid cells
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/report/threat-map", requireScope(models.ScopeReportsRead), func(c *fiber.Ctx) error {
+		var query models.ThreatMapQuery
+
+		// parse the query parameters
+		if err := c.QueryParser(&query); err != nil {
+			return parseError(err)
+		}
+
+		reportData, err := handler.ThreatMapHandler(query)
+		if err != nil {
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
@@ -231,15 +856,15 @@ func InitRouterhandlers(app *fiber.App) {
 	// Percentage
 	//
 	// responses:
-	//   200:
-	v1.Get("/report/:criteria", func(c *fiber.Ctx) error {
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/report/:criteria", requireScope(models.ScopeReportsRead), func(c *fiber.Ctx) error {
 		reportData, err := handler.InfectionNonInfectionListhandler(c.Params("criteria"))
 		if err != nil {
-			logger.Error("unable to fetch the survivor infection list", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
@@ -247,67 +872,300 @@ func InitRouterhandlers(app *fiber.App) {
 		})
 	})
 
-	// load robots list
-	// swagger:route Post /robots/load Robots idOfRobotsLoad
-	// Percentage
+	// initiate a /api/v1/admin endpoint
+	admin := v1.Group("/admin", requireScope(models.ScopeAdmin))
+
+	// create api key
+	// swagger:route POST /admin/keys Admin idOfAPIKeyCreateEndpoint
+	// create new api key, the key is only returned in this response
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	admin.Post("/keys", func(c *fiber.Ctx) error {
+		var request models.APIKey
+
+		// parse the request body
+		if err := c.BodyParser(&request); err != nil {
+			return parseError(err)
+		}
+
+		key, err := handler.NewAPIKeyHandler(principal(c), request)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully created the api key",
+			Data:       key,
+		})
+	})
+
+	// list api keys
+	// swagger:route GET /admin/keys Admin idOfAPIKeyListEndpoint
+	// list the api keys, including the revoked ones
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	admin.Get("/keys", func(c *fiber.Ctx) error {
+		keys, err := handler.ListAPIKeysHandler(principal(c))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       keys,
+		})
+	})
+
+	// revoke api key
+	// swagger:route DELETE /admin/keys/{id} Admin idOfAPIKeyRevokeEndpoint
+	// revoke the api key
 	//
 	// responses:
-	//   200:
-	v1.Post("/robots/load", func(c *fiber.Ctx) error {
-		resp, err := http.Get("https://robotstakeover20210903110417.azurewebsites.net/robotcpu")
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   500: APIResponseModel
+	admin.Delete("/keys/:id", func(c *fiber.Ctx) error {
+		key, err := handler.RevokeAPIKeyHandler(principal(c), c.Params("id"))
 		if err != nil {
-			log.Fatalln(err)
+			return err
 		}
-		//We Read the response body on the line below.
-		body, err := ioutil.ReadAll(resp.Body)
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully revoked the api key",
+			Data:       key,
+		})
+	})
+
+	// redistribute the locked inventory
+	// swagger:route POST /admin/survivors/{id}/redistribute Admin idOfRedistributeInventoryEndpoint
+	// move the locked inventory of an infected survivor to the shelter pool
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   500: APIResponseModel
+	admin.Post("/survivors/:id/redistribute", func(c *fiber.Ctx) error {
+		redistribution, err := handler.RedistributeInventoryHandler(principal(c), c.Params("id"))
 		if err != nil {
-			logger.Error("unable to fetch the robots list", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
-		var robotList []models.RobotList
-		err = json.Unmarshal(body, &robotList)
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully moved the inventory to the shelter",
+			Data:       redistribution,
+		})
+	})
 
+	// shelter pool inventory
+	// swagger:route GET /admin/shelter Admin idOfShelterEndpoint
+	// inventory of the shelter pool
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	admin.Get("/shelter", func(c *fiber.Ctx) error {
+		shelter, err := handler.ShelterInventoryHandler(principal(c))
 		if err != nil {
-			logger.Error("unable to fetch the robots list", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       shelter,
+		})
+	})
 
-		// load data to table
-		err = handler.LoadRobotsHandler(robotList)
+	// list infection appeals
+	// swagger:route GET /admin/appeals Admin idOfAppealListEndpoint
+	// list the infection appeals
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	admin.Get("/appeals", func(c *fiber.Ctx) error {
+		appeals, err := handler.ListAppealsHandler(principal(c), c.Query("status"))
 		if err != nil {
-			logger.Error("unable to store the robots list", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
-			Message:    "successfully loaded robot list",
+			Data:       appeals,
+		})
+	})
+
+	// resolve infection appeal
+	// swagger:route PUT /admin/appeals/{id} Admin idOfAppealResolveEndpoint
+	// uphold or dismiss the appeal
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	admin.Put("/appeals/:id", func(c *fiber.Ctx) error {
+		var decision models.AppealDecision
+
+		// parse the request body
+		if err := c.BodyParser(&decision); err != nil {
+			return parseError(err)
+		}
+
+		appeal, err := handler.ResolveAppealHandler(principal(c), c.Params("id"), decision)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully resolved the appeal",
+			Data:       appeal,
 		})
 	})
 
 	// load robots list
-	// swagger:route Post /robots/load Robots idOfRobotsLoad
+	// swagger:route POST /robots/load Robots idOfRobotsLoad
 	// Percentage
 	//
 	// responses:
-	//   200:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   500: APIResponseModel
+	//   502: APIResponseModel
+	v1.Post("/robots/load", requireScope(models.ScopeRobotsAdmin), func(c *fiber.Ctx) error {
+		// fetch the robots and load data to table
+		load, err := handler.LoadRobotsHandler(principal(c))
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully loaded robot list",
+			Data:       load,
+		})
+	})
+
+	// report robot sighting
+	// swagger:route POST /robots/sightings Robots idOfSightingCreateEndpoint
+	// report robots seen by a survivor
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   403: APIResponseModel
+	//   404: APIResponseModel
+	//   409: APIResponseModel
+	//   422: APIResponseModel
+	//   500: APIResponseModel
+	v1.Post("/robots/sightings", requireScope(models.ScopeSurvivorsWrite), idempotency(handler), func(c *fiber.Ctx) error {
+		var sighting models.RobotSighting
+
+		// parse the request body
+		if err := c.BodyParser(&sighting); err != nil {
+			return parseError(err)
+		}
+
+		created, err := handler.NewSightingHandler(principal(c), sighting)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "successfully reported the sighting",
+			Data:       created,
+		})
+	})
+
+	// list robot sightings
+	// swagger:route GET /robots/sightings Robots idOfSightingListEndpoint
+	// robot sightings within the area and the time window, the latest first
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/robots/sightings", func(c *fiber.Ctx) error {
+		var query models.SightingQuery
+
+		// parse the query parameters
+		if err := c.QueryParser(&query); err != nil {
+			return parseError(err)
+		}
+
+		sightings, err := handler.ListSightingsHandler(query)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Data:       sightings,
+		})
+	})
+
+	// robot sync status
+	// swagger:route GET /robots/sync-status Robots idOfRobotSyncStatus
+	// status and the latest logs of the background robot sync
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   401: APIResponseModel
+	//   500: APIResponseModel
+	v1.Get("/robots/sync-status", func(c *fiber.Ctx) error {
+		status, err := handler.RobotSyncStatusHandler(apiConfig.RobotSyncInterval)
+		if err != nil {
+			return err
+		}
+		return c.Status(http.StatusOK).JSON(models.APIResponse{
+			StatusCode: http.StatusOK,
+			Message:    "robot sync status",
+			Data:       status,
+		})
+	})
+
+	// list robots
+	// swagger:route GET /robots/list Robots idOfRobotsListEndpoint
+	// filtered, sorted and paginated list of the robots
+	//
+	// responses:
+	//   200: APIResponseModel
+	//   400: APIResponseModel
+	//   401: APIResponseModel
+	//   500: APIResponseModel
 	v1.Get("/robots/list", func(c *fiber.Ctx) error {
+		var query models.RobotQuery
+
+		// parse the query parameters
+		if err := c.QueryParser(&query); err != nil {
+			return parseError(err)
+		}
 
-		data, err := handler.ListRobotsHandler()
+		data, err := handler.ListRobotsHandler(query)
 		if err != nil {
-			logger.Error("unable to store the robots list", zap.Error(err))
-			return c.Status(http.StatusBadRequest).JSON(models.APIResponse{
-				StatusCode: http.StatusBadRequest,
-				Message:    err.Error(),
-			})
+			return err
 		}
 		return c.Status(http.StatusOK).JSON(models.APIResponse{
 			StatusCode: http.StatusOK,
@@ -316,3 +1174,152 @@ func InitRouterhandlers(app *fiber.App) {
 		})
 	})
 }
+
+// authentication middleware
+// survivors send the access token in the Authorization header, the other
+// clients send an api key in the TOKEN header. The principal is kept in the
+// locals for the scope and the role checks
+func authenticate(handler *handlers.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var principal *models.Principal
+		var err error
+		if authorization := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(authorization, "Bearer ") {
+			principal, err = handler.AuthenticateBearerHandler(strings.TrimPrefix(authorization, "Bearer "))
+		} else {
+			principal, err = handler.AuthenticateHandler(c.Get("TOKEN"))
+		}
+		if err != nil {
+			return err
+		}
+		c.Locals(principalLocal, principal)
+		return c.Next()
+	}
+}
+
+// authenticated principal of the request
+func principal(c *fiber.Ctx) models.Principal {
+	if principal, ok := c.Locals(principalLocal).(*models.Principal); ok {
+		return *principal
+	}
+	return models.Principal{}
+}
+
+// api key scope middleware
+func requireScope(scope models.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !principal(c).HasScope(scope) {
+			return handlers.Forbidden(handlers.CodeInsufficientScope, fmt.Errorf("the %s scope is required", scope))
+		}
+		return c.Next()
+	}
+}
+
+// idempotency middleware
+// requests with an Idempotency-Key header are processed once, the retried
+// requests gets the response of the first request
+func idempotency(handler *handlers.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return handlers.BadRequest(handlers.CodeBadRequest, "idempotency key is too long")
+		}
+		// the keys are scoped to the caller, so the callers can't replay the
+		// responses of each other
+		caller := principal(c)
+		key = string(caller.Role) + ":" + caller.ID + ":" + key
+
+		// same key should be retried with the same request
+		hash := sha256.Sum256(append([]byte(c.Method()+" "+c.Path()+"\n"), c.Body()...))
+		fingerprint := hex.EncodeToString(hash[:])
+
+		record, err := handler.ReserveIdempotencyKeyHandler(key, fingerprint)
+		if err != nil {
+			return err
+		}
+		switch {
+		case record == nil:
+			// reserved for this request
+		case record.Fingerprint != fingerprint:
+			return &handlers.Error{
+				Kind:    handlers.KindValidation,
+				Code:    handlers.CodeIdempotencyKeyReused,
+				Message: "idempotency key already used for another request",
+			}
+		case record.Status != models.IdempotencyCompleted:
+			return handlers.Conflict(handlers.CodeIdempotencyKeyInUse, errors.New("request with the same idempotency key is in progress"))
+		default:
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return c.Status(record.StatusCode).Send(record.Body)
+		}
+
+		// process the request and keep the response, the errors are written
+		// here, so the rejected requests are also replayed
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				handler.ReleaseIdempotencyKeyHandler(key)
+				return err
+			}
+		}
+		if c.Response().StatusCode() >= http.StatusInternalServerError {
+			handler.ReleaseIdempotencyKeyHandler(key)
+			return nil
+		}
+		if err := handler.CompleteIdempotencyKeyHandler(key, c.Response().StatusCode(), c.Response().Body()); err != nil {
+			logger.Error("unable to store the idempotent response", zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// error handler
+// converts the errors of the routes to the api response, the original error
+// is only logged
+func errorHandler(c *fiber.Ctx, err error) error {
+	response := models.APIResponse{
+		StatusCode: http.StatusInternalServerError,
+		Code:       handlers.CodeInternal,
+		Message:    "unable to process your request",
+	}
+	var handlerError *handlers.Error
+	var fiberError *fiber.Error
+	switch {
+	case errors.As(err, &handlerError):
+		response.StatusCode = handlerError.StatusCode()
+		response.Code = handlerError.Code
+		response.Message = handlerError.Message
+		response.Errors = handlerError.Fields
+	case errors.As(err, &fiberError):
+		// router errors, like the unknown routes
+		response.StatusCode = fiberError.Code
+		response.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(fiberError.Code)), " ", "_")
+		response.Message = fiberError.Message
+	}
+
+	fields := []zap.Field{
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+		zap.Int("status", response.StatusCode),
+		zap.String("code", response.Code),
+		zap.Error(err),
+	}
+	if response.StatusCode >= http.StatusInternalServerError {
+		logger.Error("unable to process the request", fields...)
+	} else {
+		logger.Info("request rejected", fields...)
+	}
+	return c.Status(response.StatusCode).JSON(response)
+}
+
+// request body or query parameters parsing error
+func parseError(err error) error {
+	return &handlers.Error{
+		Kind:    handlers.KindBadRequest,
+		Code:    handlers.CodeBadRequest,
+		Message: "unable to parse the request",
+		Err:     err,
+	}
+}
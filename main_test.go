@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"robot-apocalypse/handlers"
+	"robot-apocalypse/pkg/alerts"
+	"robot-apocalypse/pkg/auth"
+	"robot-apocalypse/pkg/db"
+	"robot-apocalypse/pkg/models"
+	"robot-apocalypse/pkg/robotsource"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const testAdminToken = "admin-token"
+
+// api on the memory storage, the admin token is bootstrapped
+func newTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	logger = zap.NewNop()
+	storage = db.NewMemoryAdapter()
+	tokens = auth.NewIssuer("secret", time.Minute, time.Hour)
+	robots = nil
+	broker = alerts.NewBroker(1000, time.Hour)
+	t.Cleanup(broker.Close)
+
+	if err := handlers.NewHandler(logger, storage, tokens, robots, broker).BootstrapAPIKeyHandler(testAdminToken); err != nil {
+		t.Fatalf("unable to bootstrap the admin token: %v", err)
+	}
+	app := fiber.New(fiber.Config{
+		AppName:      appName,
+		ErrorHandler: errorHandler,
+		Immutable:    true,
+	})
+	InitRouterhandlers(app)
+	return app
+}
+
+// send the request and decode the response
+func send(t *testing.T, app *fiber.App, method string, path string, body string, headers map[string]string) models.APIResponse {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	request := httptest.NewRequest(method, "/api/v1"+path, reader)
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	response, err := app.Test(request, -1)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer response.Body.Close()
+
+	var decoded models.APIResponse
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		t.Fatalf("%s %s: unable to decode the response: %v", method, path, err)
+	}
+	if decoded.StatusCode != response.StatusCode {
+		t.Errorf("%s %s: body status %d doesn't match the response status %d", method, path, decoded.StatusCode, response.StatusCode)
+	}
+	return decoded
+}
+
+// new api key with the role and the scopes
+func newTestKey(t *testing.T, app *fiber.App, role models.Role, survivorID string, scopes ...models.Scope) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"name": "test", "role": role, "survivor_id": survivorID, "scopes": scopes})
+	response := send(t, app, http.MethodPost, "/admin/keys", string(body), map[string]string{"TOKEN": testAdminToken})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("unable to create the api key: %+v", response)
+	}
+	data, _ := response.Data.(map[string]interface{})
+	token, _ := data["token"].(string)
+	return token
+}
+
+const testSurvivor = `{
+	"id": "srv1",
+	"name": "survivor1",
+	"age": 16,
+	"password": "correct horse battery",
+	"location": { "latitude": 10.024, "longitude": 15.14 },
+	"resources": { "water": 2, "food": 1 }
+}`
+
+func TestRouteStatusCodes(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"TOKEN": testAdminToken}
+	reports := map[string]string{"TOKEN": newTestKey(t, app, models.RoleFieldOfficer, "", models.ScopeReportsRead)}
+	if response := send(t, app, http.MethodPost, "/survivors", testSurvivor, admin); response.StatusCode != http.StatusOK {
+		t.Fatalf("unable to add the survivor: %+v", response)
+	}
+	survivor := map[string]string{"TOKEN": newTestKey(t, app, models.RoleSurvivor, "srv1", models.ScopeSurvivorsWrite)}
+	officer := map[string]string{"TOKEN": newTestKey(t, app, models.RoleFieldOfficer, "", models.ScopeAdmin)}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers map[string]string
+		status  int
+		code    string
+	}{
+		{"missing token", http.MethodGet, "/survivors", "", nil, http.StatusUnauthorized, handlers.CodeMissingToken},
+		{"unknown token", http.MethodGet, "/survivors", "", map[string]string{"TOKEN": "unknown"}, http.StatusUnauthorized, handlers.CodeInvalidToken},
+		{"invalid bearer token", http.MethodGet, "/survivors", "", map[string]string{"Authorization": "Bearer unknown"}, http.StatusUnauthorized, handlers.CodeInvalidToken},
+		{"missing scope", http.MethodPost, "/survivors", testSurvivor, reports, http.StatusForbidden, handlers.CodeInsufficientScope},
+		{"malformed body", http.MethodPost, "/survivors", `{"id":`, admin, http.StatusBadRequest, handlers.CodeBadRequest},
+		{"invalid survivor", http.MethodPost, "/survivors", `{"name":"survivor1","location":{"latitude":152.024,"longitude":15.14}}`, admin, http.StatusUnprocessableEntity, handlers.CodeValidation},
+		{"existing survivor", http.MethodPost, "/survivors", testSurvivor, admin, http.StatusConflict, handlers.CodeSurvivorExists},
+		{"survivor", http.MethodGet, "/survivors/srv1", "", admin, http.StatusOK, ""},
+		{"unknown survivor", http.MethodGet, "/survivors/unknown", "", admin, http.StatusNotFound, handlers.CodeSurvivorNotFound},
+		{"invalid cursor", http.MethodGet, "/survivors?cursor=invalid", "", admin, http.StatusBadRequest, handlers.CodeInvalidCursor},
+		{"survivor adds items", http.MethodPatch, "/survivors/srv1/inventory", `{"items":{"water":5}}`, survivor, http.StatusForbidden, handlers.CodeRoleNotAllowed},
+		{"survivor uses items", http.MethodPatch, "/survivors/srv1/inventory", `{"items":{"water":-1}}`, survivor, http.StatusOK, ""},
+		{"inventory of another survivor", http.MethodPatch, "/survivors/srv2/inventory", `{"items":{"water":-1}}`, survivor, http.StatusForbidden, handlers.CodeNotOwnRecord},
+		{"field officer lists the api keys", http.MethodGet, "/admin/keys", "", officer, http.StatusForbidden, handlers.CodeRoleNotAllowed},
+		{"insufficient stock", http.MethodPatch, "/survivors/srv1/inventory", `{"items":{"food":-2}}`, admin, http.StatusConflict, handlers.CodeInsufficientStock},
+		{"trade with an unknown survivor", http.MethodPost, "/trades", `{"from":{"id":"srv1","items":{"water":1}},"to":{"id":"unknown","items":{"food":1,"ammunition":1}}}`, admin, http.StatusNotFound, handlers.CodeSurvivorNotFound},
+		{"unknown trade", http.MethodPut, "/trades/unknown", `{"decision":"accept"}`, admin, http.StatusNotFound, handlers.CodeTradeNotFound},
+		{"zero trade quantity", http.MethodPost, "/trades", `{"from":{"id":"srv1","items":{"water":0}},"to":{"id":"srv2","items":{"food":1}}}`, admin, http.StatusUnprocessableEntity, handlers.CodeValidation},
+		{"unknown report criteria", http.MethodGet, "/report/unknown", "", reports, http.StatusBadRequest, handlers.CodeBadRequest},
+		{"robots", http.MethodGet, "/robots/list?sort=model&order=desc", "", reports, http.StatusOK, ""},
+		{"invalid robot sort", http.MethodGet, "/robots/list?sort=weight", "", reports, http.StatusBadRequest, handlers.CodeBadRequest},
+		{"invalid robot cursor", http.MethodGet, "/robots/list?cursor=invalid", "", reports, http.StatusBadRequest, handlers.CodeInvalidCursor},
+		{"sighting", http.MethodPost, "/robots/sightings", `{"category":"Land","location":{"latitude":10,"longitude":15}}`, survivor, http.StatusOK, ""},
+		{"sighting without the robot", http.MethodPost, "/robots/sightings", `{"location":{"latitude":10,"longitude":15}}`, survivor, http.StatusUnprocessableEntity, handlers.CodeValidation},
+		{"sightings without the area", http.MethodGet, "/robots/sightings?lat=10", "", reports, http.StatusBadRequest, handlers.CodeBadRequest},
+		{"empty infection reports", http.MethodGet, "/survivors/srv1/reports", "", admin, http.StatusOK, ""},
+	}
+	for _, test := range tests {
+		response := send(t, app, test.method, test.path, test.body, test.headers)
+		if response.StatusCode != test.status || response.Code != test.code {
+			t.Errorf("%s: got %d %q, want %d %q (%s)", test.name, response.StatusCode, response.Code, test.status, test.code, response.Message)
+		}
+	}
+}
+
+func TestEmptyLists(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"TOKEN": testAdminToken}
+	send(t, app, http.MethodPost, "/survivors", testSurvivor, admin)
+
+	for _, path := range []string{"/survivors/srv1/reports", "/admin/appeals", "/report/infected"} {
+		response := send(t, app, http.MethodGet, path, "", admin)
+		if items, ok := response.Data.([]interface{}); !ok || len(items) != 0 {
+			t.Errorf("%s: data = %#v, want an empty list", path, response.Data)
+		}
+	}
+}
+
+func TestRevokedKeyRejected(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"TOKEN": testAdminToken}
+	token := newTestKey(t, app, models.RoleFieldOfficer, "", models.ScopeReportsRead)
+	if response := send(t, app, http.MethodGet, "/report/percentage", "", map[string]string{"TOKEN": token}); response.StatusCode != http.StatusOK {
+		t.Fatalf("report with the new key: got %d %s, want 200", response.StatusCode, response.Code)
+	}
+
+	keys := send(t, app, http.MethodGet, "/admin/keys", "", admin)
+	listed, _ := keys.Data.([]interface{})
+	for _, item := range listed {
+		key, _ := item.(map[string]interface{})
+		if key["name"] != "test" {
+			continue
+		}
+		if response := send(t, app, http.MethodDelete, "/admin/keys/"+key["id"].(string), "", admin); response.StatusCode != http.StatusOK {
+			t.Fatalf("revoke failed: %+v", response)
+		}
+	}
+
+	response := send(t, app, http.MethodGet, "/report/percentage", "", map[string]string{"TOKEN": token})
+	if response.StatusCode != http.StatusUnauthorized || response.Code != handlers.CodeInvalidToken {
+		t.Errorf("revoked key: got %d %s, want 401 %s", response.StatusCode, response.Code, handlers.CodeInvalidToken)
+	}
+}
+
+func TestIdempotencyKeysArePerCaller(t *testing.T) {
+	app := newTestApp(t)
+	officer := newTestKey(t, app, models.RoleFieldOfficer, "", models.ScopeSurvivorsWrite)
+
+	first := send(t, app, http.MethodPost, "/survivors", testSurvivor, map[string]string{"TOKEN": testAdminToken, "Idempotency-Key": "create"})
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("create failed: %+v", first)
+	}
+	replayed := send(t, app, http.MethodPost, "/survivors", testSurvivor, map[string]string{"TOKEN": testAdminToken, "Idempotency-Key": "create"})
+	if replayed.StatusCode != http.StatusOK {
+		t.Errorf("retry with the same key: got %d %s, want the replayed 200", replayed.StatusCode, replayed.Code)
+	}
+
+	// the same key of another caller is a new request
+	other := strings.Replace(testSurvivor, `"srv1"`, `"srv2"`, 1)
+	response := send(t, app, http.MethodPost, "/survivors", other, map[string]string{"TOKEN": officer, "Idempotency-Key": "create"})
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("same key of another caller: got %d %s, want 200", response.StatusCode, response.Code)
+	}
+}
+
+func TestTradeProposal(t *testing.T) {
+	app := newTestApp(t)
+	admin := map[string]string{"TOKEN": testAdminToken}
+	send(t, app, http.MethodPost, "/survivors", testSurvivor, admin)
+	other := strings.Replace(testSurvivor, `"srv1"`, `"srv2"`, 1)
+	other = strings.Replace(other, `"resources": { "water": 2, "food": 1 }`, `"resources": { "food": 1, "ammunition": 1 }`, 1)
+	send(t, app, http.MethodPost, "/survivors", other, admin)
+	proposer := map[string]string{"TOKEN": newTestKey(t, app, models.RoleSurvivor, "srv1", models.ScopeSurvivorsWrite)}
+	receiver := map[string]string{"TOKEN": newTestKey(t, app, models.RoleSurvivor, "srv2", models.ScopeSurvivorsWrite)}
+
+	response := send(t, app, http.MethodPost, "/trades", `{"to":{"id":"srv2","items":{"food":1,"ammunition":1}},"from":{"items":{"water":1}}}`, proposer)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("trade proposal failed: %+v", response)
+	}
+	data, _ := response.Data.(map[string]interface{})
+	id, _ := data["id"].(string)
+
+	listed := send(t, app, http.MethodGet, "/survivors/srv2/trades?status=pending", "", receiver)
+	if trades, ok := listed.Data.([]interface{}); !ok || len(trades) != 1 {
+		t.Errorf("pending trades of the receiver = %#v, want the proposal", listed.Data)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		code    string
+	}{
+		{"proposer accepts", proposer, http.StatusForbidden, handlers.CodeNotOwnRecord},
+		{"receiver accepts", receiver, http.StatusOK, ""},
+		{"receiver accepts again", receiver, http.StatusConflict, handlers.CodeTradeResolved},
+	}
+	for _, test := range tests {
+		response := send(t, app, http.MethodPut, "/trades/"+id, `{"decision":"accept"}`, test.headers)
+		if response.StatusCode != test.status || response.Code != test.code {
+			t.Errorf("%s: got %d %q, want %d %q (%s)", test.name, response.StatusCode, response.Code, test.status, test.code, response.Message)
+		}
+	}
+
+	inventory := send(t, app, http.MethodGet, "/survivors/srv2/inventory", "", admin)
+	if items, _ := inventory.Data.(map[string]interface{}); items["water"] != float64(1) || items["food"] != float64(0) {
+		t.Errorf("inventory of the receiver = %v, want the water of the trade", inventory.Data)
+	}
+}
+
+// robot feed which is never reachable
+type unreachableFeed struct{}
+
+func (unreachableFeed) Fetch(ctx context.Context) (*robotsource.Feed, error) {
+	return nil, errors.New("feed is not reachable")
+}
+
+func TestSyncRobotsOnStart(t *testing.T) {
+	newTestApp(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the first sync doesn't wait for the tick
+	syncRobots(ctx, handlers.NewHandler(logger, storage, tokens, unreachableFeed{}, broker), time.Hour)
+	logs, err := storage.Robots().SyncLogs(10)
+	if err != nil {
+		t.Fatalf("unable to list the sync logs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Error == "" {
+		t.Errorf("sync logs = %+v, want the failed sync", logs)
+	}
+}
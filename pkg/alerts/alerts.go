@@ -0,0 +1,106 @@
+// package alerts
+// This package will include the proximity alert broker, the alerts are
+// pushed to the subscribers of the alerted survivor (the open event streams)
+package alerts
+
+import (
+	"robot-apocalypse/pkg/models"
+	"sync"
+	"time"
+)
+
+// alerts kept for a slow subscriber, the newer alerts are dropped when the
+// buffer is full
+const subscriberBuffer = 16
+
+// alert broker
+// keeps the subscriptions of the survivors in the process memory, so the
+// alerts are only pushed to the streams of the same api instance
+type Broker struct {
+	radius float64
+	window time.Duration
+
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[string]map[chan models.ProximityAlert]struct{}
+}
+
+// initiate new alert broker
+func NewBroker(radius float64, window time.Duration) *Broker {
+	return &Broker{
+		radius:      radius,
+		window:      window,
+		subscribers: make(map[string]map[chan models.ProximityAlert]struct{}),
+	}
+}
+
+// alert radius in meters
+func (broker *Broker) Radius() float64 {
+	return broker.radius
+}
+
+// robot sightings within the window are recent
+func (broker *Broker) Window() time.Duration {
+	return broker.window
+}
+
+// subscribe to the alerts of the survivor
+// the channel is closed on unsubscribe and when the broker is closed
+func (broker *Broker) Subscribe(survivorID string) (<-chan models.ProximityAlert, func()) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	alerts := make(chan models.ProximityAlert, subscriberBuffer)
+	if broker.closed {
+		close(alerts)
+		return alerts, func() {}
+	}
+	if broker.subscribers[survivorID] == nil {
+		broker.subscribers[survivorID] = make(map[chan models.ProximityAlert]struct{})
+	}
+	broker.subscribers[survivorID][alerts] = struct{}{}
+
+	var once sync.Once
+	return alerts, func() {
+		once.Do(func() {
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+
+			if _, ok := broker.subscribers[survivorID][alerts]; !ok {
+				return
+			}
+			delete(broker.subscribers[survivorID], alerts)
+			if len(broker.subscribers[survivorID]) == 0 {
+				delete(broker.subscribers, survivorID)
+			}
+			close(alerts)
+		})
+	}
+}
+
+// push the alert to the subscribers of the alerted survivor
+func (broker *Broker) Publish(alert models.ProximityAlert) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for alerts := range broker.subscribers[alert.SurvivorID] {
+		select {
+		case alerts <- alert:
+		default:
+		}
+	}
+}
+
+// close every subscription, so the streams are finished on shutdown
+func (broker *Broker) Close() {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	broker.closed = true
+	for survivorID, subscriptions := range broker.subscribers {
+		for alerts := range subscriptions {
+			close(alerts)
+		}
+		delete(broker.subscribers, survivorID)
+	}
+}
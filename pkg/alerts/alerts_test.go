@@ -0,0 +1,59 @@
+package alerts
+
+import (
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker(1000, time.Hour)
+	first, unsubscribeFirst := broker.Subscribe("srv1")
+	second, unsubscribeSecond := broker.Subscribe("srv1")
+	other, unsubscribeOther := broker.Subscribe("srv2")
+	defer unsubscribeSecond()
+	defer unsubscribeOther()
+
+	broker.Publish(models.ProximityAlert{Kind: models.AlertRobotSighting, SurvivorID: "srv1"})
+
+	// every stream of the survivor gets the alert
+	for name, alerts := range map[string]<-chan models.ProximityAlert{"first": first, "second": second} {
+		select {
+		case alert := <-alerts:
+			if alert.SurvivorID != "srv1" {
+				t.Errorf("%s stream: alert of %s, want srv1", name, alert.SurvivorID)
+			}
+		default:
+			t.Errorf("%s stream didn't get the alert", name)
+		}
+	}
+	select {
+	case alert := <-other:
+		t.Errorf("srv2 got the alert of srv1: %+v", alert)
+	default:
+	}
+
+	// the channel is closed on unsubscribe, repeated calls are ignored
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("unsubscribed stream is still open")
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	broker := NewBroker(1000, time.Hour)
+	alerts, unsubscribe := broker.Subscribe("srv1")
+
+	broker.Close()
+	if _, ok := <-alerts; ok {
+		t.Error("stream is open after the close")
+	}
+	// unsubscribing after the close doesn't close the channel again
+	unsubscribe()
+
+	late, _ := broker.Subscribe("srv1")
+	if _, ok := <-late; ok {
+		t.Error("subscription after the close is open")
+	}
+}
@@ -0,0 +1,77 @@
+// package auth
+// This package will include the survivor access tokens, the tokens are
+// signed JWTs (HS256) with the survivor id as the subject
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// issuer of the tokens
+const issuerName = "robot-apocalypse"
+
+// token issuer
+// signs and verifies the survivor access tokens
+type Issuer struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// initiate new token issuer
+func NewIssuer(secret string, accessTTL time.Duration, refreshTTL time.Duration) *Issuer {
+	return &Issuer{
+		secret:     []byte(secret),
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// lifetime of the access tokens
+func (issuer *Issuer) AccessTTL() time.Duration {
+	return issuer.accessTTL
+}
+
+// lifetime of the refresh tokens
+func (issuer *Issuer) RefreshTTL() time.Duration {
+	return issuer.refreshTTL
+}
+
+// sign new access token for the survivor
+func (issuer *Issuer) Sign(subject string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ID:        primitive.NewObjectID().Hex(),
+		Issuer:    issuerName,
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(issuer.accessTTL)),
+	})
+	return token.SignedString(issuer.secret)
+}
+
+// verify the access token and return the subject
+func (issuer *Issuer) Parse(tokenString string) (string, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return issuer.secret, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !claims.VerifyIssuer(issuerName, true) {
+		return "", errors.New("unexpected token issuer")
+	}
+	if claims.Subject == "" {
+		return "", errors.New("token has no subject")
+	}
+	return claims.Subject, nil
+}
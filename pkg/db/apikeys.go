@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongo api key services
+type MongoAPIKeyServices struct {
+	Collection *mongo.Collection
+}
+
+// initiate new api key services
+func NewMongoAPIKeyServices() *MongoAPIKeyServices {
+	return &MongoAPIKeyServices{}
+}
+
+// New api key entry
+// the unique hash index rejects the existing keys
+func (sr *MongoAPIKeyServices) New(data models.APIKey) error {
+	_, err := sr.Collection.InsertOne(context.TODO(), data)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAPIKeyExists
+	}
+	return err
+}
+
+// fetch the active key with the hash
+func (sr *MongoAPIKeyServices) GetByHash(hash string) (*models.APIKey, error) {
+	var collected_data *models.APIKey
+	err := sr.Collection.FindOne(context.TODO(), bson.M{
+		"hash":      hash,
+		"revokedat": nil,
+	}).Decode(&collected_data)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return collected_data, nil
+}
+
+// list api keys
+func (sr *MongoAPIKeyServices) List() ([]models.APIKey, error) {
+	var collected_data []models.APIKey
+	ctx := context.TODO()
+
+	cursor, err := sr.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdat": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.APIKey
+		err = cursor.Decode(&result)
+		if err != nil {
+			continue
+		}
+		collected_data = append(collected_data, result)
+	}
+	return collected_data, nil
+}
+
+// revoke the api key
+// revoking an already revoked key keeps the first revoked time
+func (sr *MongoAPIKeyServices) Revoke(id string) (*models.APIKey, error) {
+	var collected_data models.APIKey
+	err := sr.Collection.FindOneAndUpdate(context.TODO(), bson.M{
+		"id": id,
+	}, bson.A{
+		bson.M{"$set": bson.M{
+			"revokedat": bson.M{"$ifNull": bson.A{"$revokedat", time.Now().UTC()}},
+		}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&collected_data)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collected_data, nil
+}
+
+// change the role of the api key
+func (sr *MongoAPIKeyServices) SetRole(id string, role models.Role) error {
+	result, err := sr.Collection.UpdateOne(context.TODO(), bson.M{
+		"id": id,
+	}, bson.M{
+		"$set": bson.M{"role": role},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
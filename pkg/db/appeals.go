@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongo appeal services
+type MongoAppealServices struct {
+	Client     *mongo.Client
+	Collection *mongo.Collection
+	Survivors  *mongo.Collection
+	Reports    *mongo.Collection
+}
+
+// initiate new appeal services
+func NewMongoAppealServices() *MongoAppealServices {
+	return &MongoAppealServices{}
+}
+
+// New appeal entry
+// the appeal is only inserted when the survivor has no pending appeal
+func (sr *MongoAppealServices) New(data models.Appeal) error {
+	result, err := sr.Collection.UpdateOne(context.TODO(), bson.M{
+		"survivorid": data.SurvivorID,
+		"status":     models.AppealPending,
+	}, bson.M{
+		"$setOnInsert": data,
+	}, options.Update().SetUpsert(true))
+	// the partial unique index rejects the concurrent upserts of the same survivor
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAppealPending
+	}
+	if err != nil {
+		return err
+	}
+	if result.UpsertedCount == 0 {
+		return ErrAppealPending
+	}
+	return nil
+}
+
+// fetch the appeal details
+func (sr *MongoAppealServices) Get(id string) (*models.Appeal, error) {
+	var collected_data *models.Appeal
+	err := sr.Collection.FindOne(context.TODO(), bson.M{
+		"id": id,
+	}).Decode(&collected_data)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return collected_data, nil
+}
+
+// list appeals
+func (sr *MongoAppealServices) List(status models.AppealStatus) ([]models.Appeal, error) {
+	var collected_data []models.Appeal
+	ctx := context.TODO()
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := sr.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdat": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.Appeal
+		err = cursor.Decode(&result)
+		if err != nil {
+			continue
+		}
+		collected_data = append(collected_data, result)
+	}
+	return collected_data, nil
+}
+
+// resolve a pending appeal
+// an upheld appeal clears the infection reports of the survivor, the appeal
+// and the reports are updated inside a transaction
+func (sr *MongoAppealServices) Resolve(id string, status models.AppealStatus) (*models.Appeal, error) {
+	ctx := context.TODO()
+	session, err := sr.Client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	resolved, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var collected_data models.Appeal
+		err := sr.Collection.FindOneAndUpdate(sessCtx, bson.M{
+			"id":     id,
+			"status": models.AppealPending,
+		}, bson.M{
+			"$set": bson.M{
+				"status":     status,
+				"resolvedat": time.Now().UTC(),
+			},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&collected_data)
+
+		if err == mongo.ErrNoDocuments {
+			count, err := sr.Collection.CountDocuments(sessCtx, bson.M{"id": id})
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, ErrAppealNotFound
+			}
+			return nil, ErrAppealResolved
+		}
+		if err != nil {
+			return nil, err
+		}
+		if status != models.AppealUpheld {
+			return &collected_data, nil
+		}
+
+		result, err := sr.Survivors.UpdateOne(sessCtx, bson.M{
+			"id": collected_data.SurvivorID,
+		}, bson.M{
+			"$set": bson.M{
+				"reportedby":    bson.A{},
+				"reportedcount": 0,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, ErrSurvivorNotFound
+		}
+		_, err = sr.Reports.DeleteMany(sessCtx, bson.M{
+			"survivorid": collected_data.SurvivorID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &collected_data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(*models.Appeal), nil
+}
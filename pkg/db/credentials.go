@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongo credential services
+type MongoCredentialServices struct {
+	Collection    *mongo.Collection
+	RefreshTokens *mongo.Collection
+}
+
+// initiate new credential services
+func NewMongoCredentialServices() *MongoCredentialServices {
+	return &MongoCredentialServices{}
+}
+
+// set the survivor credentials, the existing credentials are replaced
+func (sr *MongoCredentialServices) Set(data models.SurvivorCredentials) error {
+	_, err := sr.Collection.UpdateOne(context.TODO(), bson.M{
+		"survivorid": data.SurvivorID,
+	}, bson.M{
+		"$set": data,
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// fetch the survivor credentials
+func (sr *MongoCredentialServices) Get(survivorID string) (*models.SurvivorCredentials, error) {
+	var collected_data *models.SurvivorCredentials
+	err := sr.Collection.FindOne(context.TODO(), bson.M{
+		"survivorid": survivorID,
+	}).Decode(&collected_data)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return collected_data, nil
+}
+
+// New refresh token entry
+func (sr *MongoCredentialServices) NewRefreshToken(data models.RefreshToken) error {
+	_, err := sr.RefreshTokens.InsertOne(context.TODO(), data)
+	return err
+}
+
+// revoke the active refresh token with the hash
+// the token is revoked and returned in one update, so a token can't be used
+// twice by the concurrent requests
+func (sr *MongoCredentialServices) ConsumeRefreshToken(hash string) (*models.RefreshToken, error) {
+	now := time.Now().UTC()
+	var collected_data models.RefreshToken
+	err := sr.RefreshTokens.FindOneAndUpdate(context.TODO(), bson.M{
+		"hash":      hash,
+		"revokedat": nil,
+		"expiresat": bson.M{"$gt": now},
+	}, bson.M{
+		"$set": bson.M{"revokedat": now},
+	}).Decode(&collected_data)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collected_data, nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import "errors"
+
+var (
+	// survivor entry not found in the storage
+	ErrSurvivorNotFound = errors.New("survivor not exists in the system")
+	// survivor entry with the same id already exists
+	ErrSurvivorExists = errors.New("survivor entry already exists")
+	// the inventory doesn't hold enough items for the change
+	ErrInsufficientStock = errors.New("insufficient resources in the inventory")
+	// inventory of the infected survivors are locked, they can't change
+	// their resources or trade
+	ErrInventoryLocked = errors.New("inventory of the infected survivor is locked")
+	// the reporter already reported the survivor as infected
+	ErrAlreadyReported = errors.New("survivor already reported by the reporter")
+	// the reporter has no infection report against the survivor
+	ErrReportNotFound = errors.New("infection report not exists")
+	// appeal entry not found in the storage
+	ErrAppealNotFound = errors.New("appeal not exists")
+	// the survivor already has an appeal waiting for the decision
+	ErrAppealPending = errors.New("survivor already has a pending appeal")
+	// the appeal is already upheld or dismissed
+	ErrAppealResolved = errors.New("appeal already resolved")
+	// trade proposal not found in the storage
+	ErrTradeNotFound = errors.New("trade not exists")
+	// the trade proposal is already accepted or declined
+	ErrTradeResolved = errors.New("trade already accepted or declined")
+	// the pagination cursor is malformed or created for another sorting
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	// only the locked inventories can be redistributed
+	ErrInventoryNotLocked = errors.New("inventory of the survivor is not locked")
+	// api key with the same id or hash already exists
+	ErrAPIKeyExists = errors.New("api key already exists")
+	// api key entry not found in the storage
+	ErrAPIKeyNotFound = errors.New("api key not exists")
+	// loading an empty robot list would remove every stored robot
+	ErrEmptyRobotList = errors.New("robot list is empty")
+	// robot entry not found in the robot list
+	ErrRobotNotFound = errors.New("robot not exists in the robot list")
+)
@@ -0,0 +1,20 @@
+package db
+
+import (
+	"math"
+	"robot-apocalypse/pkg/models"
+)
+
+// earth radius in meters, same as the mongo db spherical queries
+const earthRadius = 6378100.0
+
+// great-circle distance between the locations in meters
+func Distance(a models.Location, b models.Location) float64 {
+	lat1 := float64(a.Latitude) * math.Pi / 180
+	lat2 := float64(b.Latitude) * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := float64(b.Longitude-a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// idempotency keys are kept for a day
+const idempotencyKeyTTL = 24 * time.Hour
+
+// mongo idempotency services
+type MongoIdempotencyServices struct {
+	Collection *mongo.Collection
+}
+
+// initiate new idempotency services
+func NewMongoIdempotencyServices() *MongoIdempotencyServices {
+	return &MongoIdempotencyServices{}
+}
+
+// reserve the idempotency key
+// the upsert only inserts when the key is not used, the unique key index
+// rejects the concurrent reservations of the same key
+func (sr *MongoIdempotencyServices) Reserve(key string, fingerprint string) (*models.IdempotencyRecord, error) {
+	ctx := context.TODO()
+	result, err := sr.Collection.UpdateOne(ctx, bson.M{
+		"key": key,
+	}, bson.M{
+		"$setOnInsert": models.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      models.IdempotencyPending,
+			CreatedAt:   time.Now().UTC(),
+		},
+	}, options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+	if err == nil && result.UpsertedCount > 0 {
+		return nil, nil
+	}
+
+	// already used key
+	var collected_data models.IdempotencyRecord
+	err = sr.Collection.FindOne(ctx, bson.M{
+		"key": key,
+	}).Decode(&collected_data)
+	if err != nil {
+		return nil, err
+	}
+	return &collected_data, nil
+}
+
+// keep the response of the request
+func (sr *MongoIdempotencyServices) Complete(key string, statusCode int, body []byte) error {
+	_, err := sr.Collection.UpdateOne(context.TODO(), bson.M{
+		"key": key,
+	}, bson.M{
+		"$set": bson.M{
+			"status":     models.IdempotencyCompleted,
+			"statuscode": statusCode,
+			"body":       body,
+		},
+	})
+	return err
+}
+
+// remove the reservation
+func (sr *MongoIdempotencyServices) Release(key string) error {
+	_, err := sr.Collection.DeleteOne(context.TODO(), bson.M{
+		"key":    key,
+		"status": models.IdempotencyPending,
+	})
+	return err
+}
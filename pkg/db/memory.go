@@ -0,0 +1,132 @@
+package db
+
+import (
+	"sync"
+
+	"robot-apocalypse/pkg/models"
+)
+
+// in-memory storage adapter
+// Keeps every collection in the process memory, so the api can run
+// without a mongo db instance (local development, CI)
+type MemoryAdapter struct {
+	mu sync.RWMutex
+
+	survivors       map[string]*models.Survivor           // survivors by id
+	survivorOrder   []string                              // insertion order of the survivors
+	locationHistory []models.LocationHistory              // survivors location history
+	robots          []models.RobotList                    // robots list
+	robotSyncLog    []models.RobotSyncLog                 // robot sync log
+	sightings       []models.RobotSighting                // robot sightings
+	shelter         models.Resources                      // shelter pool inventory
+	appeals         []models.Appeal                       // infection appeals
+	trades          []models.TradeProposal                // trade proposals
+	reports         []models.InfectionReportRecord        // infection reports
+	idempotency     map[string]*models.IdempotencyRecord  // idempotency keys
+	apiKeys         []models.APIKey                       // api keys
+	credentials     map[string]models.SurvivorCredentials // survivor credentials by survivor id
+	refreshTokens   map[string]models.RefreshToken        // refresh tokens by hash
+}
+
+var _ Services = (*MemoryAdapter)(nil)
+
+// initiate new in-memory storage
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{
+		survivors:     make(map[string]*models.Survivor),
+		shelter:       models.Resources{},
+		idempotency:   make(map[string]*models.IdempotencyRecord),
+		credentials:   make(map[string]models.SurvivorCredentials),
+		refreshTokens: make(map[string]models.RefreshToken),
+	}
+}
+
+// no indexes for the in-memory storage
+func (adptr *MemoryAdapter) EnsureIndexes() error {
+	return nil
+}
+
+// survivor service
+func (adptr *MemoryAdapter) Survivors() SurvivorServices {
+	return &MemorySurvivorServices{store: adptr}
+}
+
+// robots service
+func (adptr *MemoryAdapter) Robots() RobotsServices {
+	return &MemoryRobotsServices{store: adptr}
+}
+
+// robot sighting service
+func (adptr *MemoryAdapter) Sightings() SightingServices {
+	return &MemorySightingServices{store: adptr}
+}
+
+// shelter service
+func (adptr *MemoryAdapter) Shelter() ShelterServices {
+	return &MemoryShelterServices{store: adptr}
+}
+
+// appeal service
+func (adptr *MemoryAdapter) Appeals() AppealServices {
+	return &MemoryAppealServices{store: adptr}
+}
+
+// trade service
+func (adptr *MemoryAdapter) Trades() TradeServices {
+	return &MemoryTradeServices{store: adptr}
+}
+
+// infection report service
+func (adptr *MemoryAdapter) Reports() InfectionReportServices {
+	return &MemoryInfectionReportServices{store: adptr}
+}
+
+// idempotency service
+func (adptr *MemoryAdapter) Idempotency() IdempotencyServices {
+	return &MemoryIdempotencyServices{store: adptr}
+}
+
+// api key service
+func (adptr *MemoryAdapter) APIKeys() APIKeyServices {
+	return &MemoryAPIKeyServices{store: adptr}
+}
+
+// credential service
+func (adptr *MemoryAdapter) Credentials() CredentialServices {
+	return &MemoryCredentialServices{store: adptr}
+}
+
+// memory shelter services
+type MemoryShelterServices struct {
+	store *MemoryAdapter
+}
+
+// shelter pool inventory
+func (sr *MemoryShelterServices) Inventory() (models.Resources, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	return cloneResources(sr.store.shelter), nil
+}
+
+// copy of the survivor, so the callers never share the stored inventory
+// and reporters list
+func cloneSurvivor(sr models.Survivor) models.Survivor {
+	sr.Resources = cloneResources(sr.Resources)
+	if sr.ReportedBy != nil {
+		sr.ReportedBy = append([]string{}, sr.ReportedBy...)
+	}
+	return sr
+}
+
+// copy of the inventory
+func cloneResources(res models.Resources) models.Resources {
+	if res == nil {
+		return nil
+	}
+	cloned := make(models.Resources, len(res))
+	for item, quantity := range res {
+		cloned[item] = quantity
+	}
+	return cloned
+}
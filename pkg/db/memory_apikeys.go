@@ -0,0 +1,81 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// memory api key services
+type MemoryAPIKeyServices struct {
+	store *MemoryAdapter
+}
+
+// New api key entry
+func (sr *MemoryAPIKeyServices) New(data models.APIKey) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	for _, key := range sr.store.apiKeys {
+		if key.ID == data.ID || key.Hash == data.Hash {
+			return ErrAPIKeyExists
+		}
+	}
+	data.Scopes = append([]models.Scope{}, data.Scopes...)
+	sr.store.apiKeys = append(sr.store.apiKeys, data)
+	return nil
+}
+
+// fetch the active key with the hash
+func (sr *MemoryAPIKeyServices) GetByHash(hash string) (*models.APIKey, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	for _, key := range sr.store.apiKeys {
+		if key.Hash == hash && key.RevokedAt == nil {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+// list api keys
+func (sr *MemoryAPIKeyServices) List() ([]models.APIKey, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	return append([]models.APIKey(nil), sr.store.apiKeys...), nil
+}
+
+// revoke the api key
+func (sr *MemoryAPIKeyServices) Revoke(id string) (*models.APIKey, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	for i := range sr.store.apiKeys {
+		key := &sr.store.apiKeys[i]
+		if key.ID != id {
+			continue
+		}
+		if key.RevokedAt == nil {
+			revokedAt := time.Now().UTC()
+			key.RevokedAt = &revokedAt
+		}
+		revoked := *key
+		return &revoked, nil
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+// change the role of the api key
+func (sr *MemoryAPIKeyServices) SetRole(id string, role models.Role) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	for i := range sr.store.apiKeys {
+		if sr.store.apiKeys[i].ID == id {
+			sr.store.apiKeys[i].Role = role
+			return nil
+		}
+	}
+	return ErrAPIKeyNotFound
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// memory appeal services
+type MemoryAppealServices struct {
+	store *MemoryAdapter
+}
+
+// New appeal entry
+func (sr *MemoryAppealServices) New(data models.Appeal) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	for _, appeal := range sr.store.appeals {
+		if appeal.SurvivorID == data.SurvivorID && appeal.Status == models.AppealPending {
+			return ErrAppealPending
+		}
+	}
+	sr.store.appeals = append(sr.store.appeals, data)
+	return nil
+}
+
+// fetch the appeal details
+func (sr *MemoryAppealServices) Get(id string) (*models.Appeal, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	for _, appeal := range sr.store.appeals {
+		if appeal.ID == id {
+			return &appeal, nil
+		}
+	}
+	return nil, nil
+}
+
+// list appeals
+func (sr *MemoryAppealServices) List(status models.AppealStatus) ([]models.Appeal, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	var collected_data []models.Appeal
+	for _, appeal := range sr.store.appeals {
+		if status == "" || appeal.Status == status {
+			collected_data = append(collected_data, appeal)
+		}
+	}
+	return collected_data, nil
+}
+
+// resolve a pending appeal
+// an upheld appeal clears the infection reports of the survivor
+func (sr *MemoryAppealServices) Resolve(id string, status models.AppealStatus) (*models.Appeal, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	for i := range sr.store.appeals {
+		appeal := &sr.store.appeals[i]
+		if appeal.ID != id {
+			continue
+		}
+		if appeal.Status != models.AppealPending {
+			return nil, ErrAppealResolved
+		}
+		entry, ok := sr.store.survivors[appeal.SurvivorID]
+		if status == models.AppealUpheld && !ok {
+			return nil, ErrSurvivorNotFound
+		}
+		resolvedAt := time.Now().UTC()
+		appeal.Status = status
+		appeal.ResolvedAt = &resolvedAt
+		if status == models.AppealUpheld {
+			entry.ReportedBy = nil
+			entry.ReportedCount = 0
+			sr.store.removeReports(func(report models.InfectionReportRecord) bool {
+				return report.SurvivorID == appeal.SurvivorID
+			})
+		}
+		resolved := *appeal
+		return &resolved, nil
+	}
+	return nil, ErrAppealNotFound
+}
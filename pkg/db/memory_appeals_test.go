@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+// pending appeal of the survivor
+func newTestAppeal(id string, survivorID string) models.Appeal {
+	return models.Appeal{ID: id, SurvivorID: survivorID, Status: models.AppealPending, CreatedAt: time.Now().UTC()}
+}
+
+func TestAppealPendingOnce(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1"})
+
+	if err := store.Appeals().New(newTestAppeal("a1", "srv1")); err != nil {
+		t.Fatalf("appeal failed: %v", err)
+	}
+	if err := store.Appeals().New(newTestAppeal("a2", "srv1")); !errors.Is(err, ErrAppealPending) {
+		t.Errorf("second appeal: error = %v, want %v", err, ErrAppealPending)
+	}
+
+	// a new appeal is accepted once the previous one is resolved
+	if _, err := store.Appeals().Resolve("a1", models.AppealDismissed); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if err := store.Appeals().New(newTestAppeal("a3", "srv1")); err != nil {
+		t.Errorf("appeal after the resolution failed: %v", err)
+	}
+}
+
+func TestResolveAppeal(t *testing.T) {
+	tests := []struct {
+		status   models.AppealStatus
+		reported int
+	}{
+		{models.AppealUpheld, 0},
+		{models.AppealDismissed, 2},
+	}
+	for _, test := range tests {
+		t.Run(string(test.status), func(t *testing.T) {
+			store := newTestStore(t, models.Survivor{ID: "srv1"})
+			for _, reporter := range []string{"srv2", "srv3"} {
+				if err := store.Survivors().Infected(newTestReport("srv1", reporter)); err != nil {
+					t.Fatalf("infection report failed: %v", err)
+				}
+			}
+			if err := store.Appeals().New(newTestAppeal("a1", "srv1")); err != nil {
+				t.Fatalf("appeal failed: %v", err)
+			}
+
+			appeal, err := store.Appeals().Resolve("a1", test.status)
+			if err != nil {
+				t.Fatalf("resolve failed: %v", err)
+			}
+			if appeal.Status != test.status || appeal.ResolvedAt == nil {
+				t.Errorf("appeal = %+v, want %s with the resolved time", appeal, test.status)
+			}
+			if survivor, _ := store.Survivors().GetSurvivor("srv1"); survivor == nil || survivor.ReportedCount != test.reported {
+				t.Errorf("survivor = %+v, want %d reports", survivor, test.reported)
+			}
+			if reports, err := store.Reports().List("srv1"); err != nil || len(reports) != test.reported {
+				t.Errorf("reports = %+v, %v, want %d records", reports, err, test.reported)
+			}
+			if _, err := store.Appeals().Resolve("a1", test.status); !errors.Is(err, ErrAppealResolved) {
+				t.Errorf("second resolve: error = %v, want %v", err, ErrAppealResolved)
+			}
+		})
+	}
+
+	store := NewMemoryAdapter()
+	if _, err := store.Appeals().Resolve("unknown", models.AppealUpheld); !errors.Is(err, ErrAppealNotFound) {
+		t.Errorf("unknown appeal: error = %v, want %v", err, ErrAppealNotFound)
+	}
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// memory credential services
+type MemoryCredentialServices struct {
+	store *MemoryAdapter
+}
+
+// set the survivor credentials
+func (sr *MemoryCredentialServices) Set(data models.SurvivorCredentials) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	data.PasswordHash = append([]byte{}, data.PasswordHash...)
+	sr.store.credentials[data.SurvivorID] = data
+	return nil
+}
+
+// fetch the survivor credentials
+func (sr *MemoryCredentialServices) Get(survivorID string) (*models.SurvivorCredentials, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	credentials, ok := sr.store.credentials[survivorID]
+	if !ok {
+		return nil, nil
+	}
+	return &credentials, nil
+}
+
+// New refresh token entry
+func (sr *MemoryCredentialServices) NewRefreshToken(data models.RefreshToken) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	sr.store.refreshTokens[data.Hash] = data
+	return nil
+}
+
+// revoke the active refresh token with the hash
+func (sr *MemoryCredentialServices) ConsumeRefreshToken(hash string) (*models.RefreshToken, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	token, ok := sr.store.refreshTokens[hash]
+	now := time.Now().UTC()
+	if !ok || token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	consumed := token
+	token.RevokedAt = &now
+	sr.store.refreshTokens[hash] = token
+	return &consumed, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// memory idempotency services
+type MemoryIdempotencyServices struct {
+	store *MemoryAdapter
+}
+
+// reserve the idempotency key
+func (sr *MemoryIdempotencyServices) Reserve(key string, fingerprint string) (*models.IdempotencyRecord, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if record, ok := sr.store.idempotency[key]; ok && time.Since(record.CreatedAt) < idempotencyKeyTTL {
+		existing := *record
+		return &existing, nil
+	}
+	sr.store.idempotency[key] = &models.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Status:      models.IdempotencyPending,
+		CreatedAt:   time.Now().UTC(),
+	}
+	return nil, nil
+}
+
+// keep the response of the request
+func (sr *MemoryIdempotencyServices) Complete(key string, statusCode int, body []byte) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if record, ok := sr.store.idempotency[key]; ok {
+		record.Status = models.IdempotencyCompleted
+		record.StatusCode = statusCode
+		record.Body = append([]byte{}, body...)
+	}
+	return nil
+}
+
+// remove the reservation
+func (sr *MemoryIdempotencyServices) Release(key string) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if record, ok := sr.store.idempotency[key]; ok && record.Status == models.IdempotencyPending {
+		delete(sr.store.idempotency, key)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	store := NewMemoryAdapter()
+
+	if record, err := store.Idempotency().Reserve("key1", "create srv1"); err != nil || record != nil {
+		t.Fatalf("first reservation = %+v, %v, want the key reserved", record, err)
+	}
+	// the released key can be reserved again
+	if err := store.Idempotency().Release("key1"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if record, err := store.Idempotency().Reserve("key1", "create srv1"); err != nil || record != nil {
+		t.Fatalf("reservation after the release = %+v, %v, want the key reserved", record, err)
+	}
+
+	if err := store.Idempotency().Complete("key1", http.StatusCreated, []byte(`{"id":"srv1"}`)); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	// the completed key isn't released, the retries get the kept response
+	if err := store.Idempotency().Release("key1"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	record, err := store.Idempotency().Reserve("key1", "create srv1")
+	if err != nil || record == nil {
+		t.Fatalf("retried reservation = %+v, %v, want the completed record", record, err)
+	}
+	if record.StatusCode != http.StatusCreated || string(record.Body) != `{"id":"srv1"}` || record.Fingerprint != "create srv1" {
+		t.Errorf("record = %+v, want the kept response", record)
+	}
+}
@@ -0,0 +1,33 @@
+package db
+
+import "robot-apocalypse/pkg/models"
+
+// memory infection report services
+type MemoryInfectionReportServices struct {
+	store *MemoryAdapter
+}
+
+// list the infection reports against the survivor
+func (sr *MemoryInfectionReportServices) List(survivorID string) ([]models.InfectionReportRecord, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	var collected_data []models.InfectionReportRecord
+	for _, report := range sr.store.reports {
+		if report.SurvivorID == survivorID {
+			collected_data = append(collected_data, report)
+		}
+	}
+	return collected_data, nil
+}
+
+// remove the matching reports, the caller holds the lock
+func (adptr *MemoryAdapter) removeReports(match func(models.InfectionReportRecord) bool) {
+	remaining := adptr.reports[:0]
+	for _, report := range adptr.reports {
+		if !match(report) {
+			remaining = append(remaining, report)
+		}
+	}
+	adptr.reports = remaining
+}
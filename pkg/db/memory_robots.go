@@ -0,0 +1,235 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"sort"
+	"strings"
+)
+
+// memory robots services
+type MemoryRobotsServices struct {
+	store *MemoryAdapter
+}
+
+// replace the stored robot list, the list is swapped under the lock
+func (sr *MemoryRobotsServices) LoadData(data []models.RobotList) error {
+	if len(data) == 0 {
+		return ErrEmptyRobotList
+	}
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	// the robots are upserted by the serial number like in mongo, the last
+	// robot of a serial number is kept
+	robots := make([]models.RobotList, 0, len(data))
+	index := make(map[string]int, len(data))
+	for _, robot := range data {
+		if i, ok := index[robot.SerialNumber]; ok {
+			robots[i] = robot
+			continue
+		}
+		index[robot.SerialNumber] = len(robots)
+		robots = append(robots, robot)
+	}
+	sr.store.robots = robots
+	return nil
+}
+
+// list robots
+func (sr *MemoryRobotsServices) ListData() ([]models.RobotList, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	if len(sr.store.robots) == 0 {
+		return nil, nil
+	}
+	return append([]models.RobotList{}, sr.store.robots...), nil
+}
+
+// fetch the robot with the serial number
+func (sr *MemoryRobotsServices) GetRobot(serialNumber string) (*models.RobotList, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	for _, robot := range sr.store.robots {
+		if robot.SerialNumber == serialNumber {
+			return &robot, nil
+		}
+	}
+	return nil, nil
+}
+
+// filtered, sorted and paginated robots listing
+func (sr *MemoryRobotsServices) ListRobots(query models.RobotQuery) (*models.RobotPage, error) {
+	var cursorValue interface{}
+	var cursorSerialNumber string
+	if query.Cursor != "" {
+		var err error
+		cursorValue, cursorSerialNumber, err = decodeRobotCursor(query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	order := 1
+	if query.Order == "desc" {
+		order = -1
+	}
+	// position of the robot against the cursor on the sort order
+	compare := func(robot models.RobotList, value interface{}, serialNumber string) int {
+		result := compareSortValues(robotSortValue(robot, query.SortBy), value)
+		if result == 0 {
+			result = strings.Compare(robot.SerialNumber, serialNumber)
+		}
+		return result * order
+	}
+
+	sr.store.mu.RLock()
+	robots := make([]models.RobotList, 0, len(sr.store.robots))
+	for _, robot := range sr.store.robots {
+		switch {
+		case query.Category != "" && robot.Category != query.Category,
+			query.Model != "" && !strings.HasPrefix(robot.Model, query.Model),
+			query.SerialNumber != "" && robot.SerialNumber != query.SerialNumber,
+			!query.ManufacturedFrom.IsZero() && robot.ManufacturedAt.Before(query.ManufacturedFrom),
+			!query.ManufacturedTo.IsZero() && robot.ManufacturedAt.After(query.ManufacturedTo),
+			query.Cursor != "" && compare(robot, cursorValue, cursorSerialNumber) <= 0:
+			continue
+		}
+		robots = append(robots, robot)
+	}
+	sr.store.mu.RUnlock()
+
+	sort.Slice(robots, func(i, j int) bool {
+		return compare(robots[i], robotSortValue(robots[j], query.SortBy), robots[j].SerialNumber) < 0
+	})
+
+	page := &models.RobotPage{Robots: robots}
+	if len(robots) > query.Limit {
+		page.Robots = robots[:query.Limit]
+		var err error
+		page.NextCursor, err = encodeRobotCursor(page.Robots[query.Limit-1], query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// robot report
+func (sr *MemoryRobotsServices) Report() (*models.RobotReport, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	report := &models.RobotReport{Total: len(sr.store.robots)}
+	categories := map[string]int{}
+	robotModels := map[string]int{}
+	years := map[int]int{}
+	for i, robot := range sr.store.robots {
+		categories[string(robot.Category)]++
+		robotModels[robot.Model]++
+		if robot.ManufacturedAt.IsZero() {
+			continue
+		}
+		years[robot.ManufacturedAt.Year()]++
+		if report.Oldest == nil || robotBefore(robot, *report.Oldest) {
+			report.Oldest = &sr.store.robots[i]
+		}
+		if report.Newest == nil || robotBefore(*report.Newest, robot) {
+			report.Newest = &sr.store.robots[i]
+		}
+	}
+	report.ByCategory = robotCounts(categories)
+	report.ByModel = robotCounts(robotModels)
+	report.ByYear = make([]models.RobotYearCount, 0, len(years))
+	for year, count := range years {
+		report.ByYear = append(report.ByYear, models.RobotYearCount{Year: year, Count: count})
+	}
+	sort.Slice(report.ByYear, func(i, j int) bool {
+		return report.ByYear[i].Year < report.ByYear[j].Year
+	})
+	// copies, the stored robots can be replaced after the lock is released
+	if report.Oldest != nil {
+		oldest := *report.Oldest
+		report.Oldest = &oldest
+	}
+	if report.Newest != nil {
+		newest := *report.Newest
+		report.Newest = &newest
+	}
+	return report, nil
+}
+
+// a is manufactured before b, the serial number breaks the ties
+func robotBefore(a models.RobotList, b models.RobotList) bool {
+	if a.ManufacturedAt.Equal(b.ManufacturedAt) {
+		return a.SerialNumber < b.SerialNumber
+	}
+	return a.ManufacturedAt.Before(b.ManufacturedAt)
+}
+
+// counts sorted as the report pipeline, the largest first and the value
+// breaks the ties
+func robotCounts(counts map[string]int) []models.RobotCount {
+	collected_data := make([]models.RobotCount, 0, len(counts))
+	for value, count := range counts {
+		collected_data = append(collected_data, models.RobotCount{Value: value, Count: count})
+	}
+	sort.Slice(collected_data, func(i, j int) bool {
+		if collected_data[i].Count != collected_data[j].Count {
+			return collected_data[i].Count > collected_data[j].Count
+		}
+		return collected_data[i].Value < collected_data[j].Value
+	})
+	return collected_data
+}
+
+// apply the changes of a sync
+func (sr *MemoryRobotsServices) Sync(upserts []models.RobotList, removals []string) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	removed := make(map[string]bool, len(removals))
+	for _, serialNumber := range removals {
+		removed[serialNumber] = true
+	}
+	robots := make([]models.RobotList, 0, len(sr.store.robots)+len(upserts))
+	index := make(map[string]int, len(sr.store.robots))
+	for _, robot := range sr.store.robots {
+		if removed[robot.SerialNumber] {
+			continue
+		}
+		index[robot.SerialNumber] = len(robots)
+		robots = append(robots, robot)
+	}
+	for _, robot := range upserts {
+		if i, ok := index[robot.SerialNumber]; ok {
+			robots[i] = robot
+			continue
+		}
+		index[robot.SerialNumber] = len(robots)
+		robots = append(robots, robot)
+	}
+	sr.store.robots = robots
+	return nil
+}
+
+// New sync log entry
+func (sr *MemoryRobotsServices) NewSyncLog(entry models.RobotSyncLog) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	sr.store.robotSyncLog = append(sr.store.robotSyncLog, entry)
+	return nil
+}
+
+// latest sync log entries
+func (sr *MemoryRobotsServices) SyncLogs(limit int) ([]models.RobotSyncLog, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	var collected_data []models.RobotSyncLog
+	for i := len(sr.store.robotSyncLog) - 1; i >= 0 && len(collected_data) < limit; i-- {
+		collected_data = append(collected_data, sr.store.robotSyncLog[i])
+	}
+	return collected_data, nil
+}
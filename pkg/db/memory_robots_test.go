@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestMemoryRobotsLoad(t *testing.T) {
+	store := NewMemoryAdapter()
+	if robots, err := store.Robots().ListData(); err != nil || robots != nil {
+		t.Errorf("robots before the load = %+v, %v, want none", robots, err)
+	}
+
+	first := []models.RobotList{{SerialNumber: "S1"}, {SerialNumber: "S2"}}
+	second := []models.RobotList{{SerialNumber: "S3"}}
+	for _, robots := range [][]models.RobotList{first, second} {
+		if err := store.Robots().LoadData(robots); err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+	}
+
+	// the load replaces the previous list
+	robots, err := store.Robots().ListData()
+	if err != nil || len(robots) != 1 || robots[0].SerialNumber != "S3" {
+		t.Errorf("robots = %+v, %v, want only S3", robots, err)
+	}
+
+	// an empty list doesn't wipe the stored robots
+	if err := store.Robots().LoadData(nil); !errors.Is(err, ErrEmptyRobotList) {
+		t.Errorf("empty load: error = %v, want %v", err, ErrEmptyRobotList)
+	}
+	if robots, _ := store.Robots().ListData(); len(robots) != 1 {
+		t.Errorf("robots after the empty load = %+v, want S3 kept", robots)
+	}
+
+	// the serial numbers stay unique
+	err = store.Robots().LoadData([]models.RobotList{{SerialNumber: "S1", Model: "R1"}, {SerialNumber: "S1", Model: "R2"}})
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if robots, _ := store.Robots().ListData(); len(robots) != 1 || robots[0].Model != "R2" {
+		t.Errorf("robots = %+v, want only the last S1", robots)
+	}
+}
+
+func TestMemoryRobotReport(t *testing.T) {
+	store := NewMemoryAdapter()
+	if report, err := store.Robots().Report(); err != nil || report.Total != 0 || report.Oldest != nil {
+		t.Errorf("report of no robots = %+v, %v, want it empty", report, err)
+	}
+
+	manufactured := func(year int) time.Time {
+		return time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC)
+	}
+	err := store.Robots().LoadData([]models.RobotList{
+		{SerialNumber: "S1", Model: "R2", Category: models.RobotCategoryLand, ManufacturedAt: manufactured(2020)},
+		{SerialNumber: "S2", Model: "R2", Category: models.RobotCategoryFlying, ManufacturedAt: manufactured(2021)},
+		{SerialNumber: "S3", Model: "C3", Category: models.RobotCategoryLand, ManufacturedAt: manufactured(2020)},
+		{SerialNumber: "S4", Model: "C3", Category: models.RobotCategoryLand},
+	})
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	report, err := store.Robots().Report()
+	if err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+	if report.Total != 4 {
+		t.Errorf("total = %d, want 4", report.Total)
+	}
+	if got := fmt.Sprint(report.ByCategory); got != "[{Land 3} {Flying 1}]" {
+		t.Errorf("by category = %s, want 3 land and 1 flying", got)
+	}
+	// the count ties are sorted by the value
+	if got := fmt.Sprint(report.ByModel); got != "[{C3 2} {R2 2}]" {
+		t.Errorf("by model = %s, want 2 C3 and 2 R2", got)
+	}
+	// the robot without a date is left out of the years
+	if got := fmt.Sprint(report.ByYear); got != "[{2020 2} {2021 1}]" {
+		t.Errorf("by year = %s, want 2 of 2020 and 1 of 2021", got)
+	}
+	if report.Oldest == nil || report.Oldest.SerialNumber != "S1" || report.Newest == nil || report.Newest.SerialNumber != "S2" {
+		t.Errorf("oldest %+v and newest %+v, want S1 and S2", report.Oldest, report.Newest)
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"sort"
+)
+
+// memory robot sighting services
+type MemorySightingServices struct {
+	store *MemoryAdapter
+}
+
+// New robot sighting entry
+func (sr *MemorySightingServices) New(data models.RobotSighting) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	sr.store.sightings = append(sr.store.sightings, data)
+	return nil
+}
+
+// sightings within the area and the time window
+func (sr *MemorySightingServices) List(query models.SightingQuery) ([]models.RobotSighting, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	collected_data := []models.RobotSighting{}
+	for _, sighting := range sr.store.sightings {
+		switch {
+		case query.Latitude != nil && query.Longitude != nil &&
+			Distance(models.Location{Latitude: float32(*query.Latitude), Longitude: float32(*query.Longitude)}, sighting.Location) > query.Radius,
+			!query.SeenFrom.IsZero() && sighting.SeenAt.Before(query.SeenFrom),
+			!query.SeenTo.IsZero() && sighting.SeenAt.After(query.SeenTo),
+			query.SerialNumber != "" && sighting.SerialNumber != query.SerialNumber,
+			query.Category != "" && sighting.Category != query.Category:
+			continue
+		}
+		collected_data = append(collected_data, sighting)
+	}
+
+	sort.SliceStable(collected_data, func(i, j int) bool {
+		if collected_data[i].SeenAt.Equal(collected_data[j].SeenAt) {
+			return collected_data[i].ID > collected_data[j].ID
+		}
+		return collected_data[i].SeenAt.After(collected_data[j].SeenAt)
+	})
+	if len(collected_data) > query.Limit {
+		collected_data = collected_data[:query.Limit]
+	}
+	return collected_data, nil
+}
+
+// sightings grouped into the grid cells
+func (sr *MemorySightingServices) ThreatMap(query models.ThreatMapQuery) ([]models.ThreatCell, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	type position struct{ row, column int }
+	cells := map[position]*models.ThreatCell{}
+	for _, sighting := range sr.store.sightings {
+		latitude, longitude := float64(sighting.Location.Latitude), float64(sighting.Location.Longitude)
+		switch {
+		case sighting.SeenAt.Before(query.SeenFrom),
+			sighting.SeenAt.After(query.SeenTo),
+			query.Category != "" && sighting.Category != query.Category,
+			query.MinLatitude != nil && (latitude < *query.MinLatitude || latitude > *query.MaxLatitude ||
+				longitude < *query.MinLongitude || longitude > *query.MaxLongitude):
+			continue
+		}
+		row, column := threatCellOf(sighting.Location, query.CellSize)
+		entry, ok := cells[position{row, column}]
+		if !ok {
+			threatCell := newThreatCell(row, column, query.CellSize)
+			entry = &threatCell
+			cells[position{row, column}] = entry
+		}
+		entry.Sightings++
+		entry.Robots += sighting.Count
+	}
+
+	collected_data := make([]models.ThreatCell, 0, len(cells))
+	for _, entry := range cells {
+		collected_data = append(collected_data, *entry)
+	}
+	sortThreatCells(collected_data)
+	return collected_data, nil
+}
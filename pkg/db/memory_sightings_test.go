@@ -0,0 +1,119 @@
+package db
+
+import (
+	"fmt"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestSightingsList(t *testing.T) {
+	store := NewMemoryAdapter()
+	now := time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC)
+	sightings := []models.RobotSighting{
+		{ID: "a", SerialNumber: "S1", Category: models.RobotCategoryLand, Location: models.Location{Latitude: 10, Longitude: 20}, SeenAt: now.Add(-time.Hour)},
+		{ID: "b", SerialNumber: "S2", Category: models.RobotCategoryFlying, Location: models.Location{Latitude: 10.01, Longitude: 20}, SeenAt: now},
+		{ID: "c", SerialNumber: "S1", Category: models.RobotCategoryLand, Location: models.Location{Latitude: 10, Longitude: 20.01}, SeenAt: now},
+		// outside of the area
+		{ID: "d", SerialNumber: "S1", Category: models.RobotCategoryLand, Location: models.Location{Latitude: 50, Longitude: 20}, SeenAt: now},
+	}
+	for _, sighting := range sightings {
+		if err := store.Sightings().New(sighting); err != nil {
+			t.Fatalf("sighting %s failed: %v", sighting.ID, err)
+		}
+	}
+	latitude, longitude := 10.0, 20.0
+
+	tests := []struct {
+		name  string
+		query models.SightingQuery
+		want  string
+	}{
+		// the latest first, the id breaks the ties
+		{"area", models.SightingQuery{}, "[c b a]"},
+		{"time window", models.SightingQuery{SeenFrom: now.Add(-time.Minute)}, "[c b]"},
+		{"serial number", models.SightingQuery{SerialNumber: "S1", SeenTo: now.Add(-time.Minute)}, "[a]"},
+		{"category", models.SightingQuery{Category: models.RobotCategoryFlying}, "[b]"},
+		{"limit", models.SightingQuery{Limit: 1}, "[c]"},
+	}
+	for _, test := range tests {
+		query := test.query
+		query.Latitude, query.Longitude, query.Radius = &latitude, &longitude, 5000
+		if query.Limit == 0 {
+			query.Limit = 10
+		}
+		listed, err := store.Sightings().List(query)
+		if err != nil {
+			t.Fatalf("%s: list failed: %v", test.name, err)
+		}
+		var ids []string
+		for _, sighting := range listed {
+			ids = append(ids, sighting.ID)
+		}
+		if got := fmt.Sprint(ids); got != test.want {
+			t.Errorf("%s: sightings = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestThreatMapBuckets(t *testing.T) {
+	store := NewMemoryAdapter()
+	now := time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC)
+	sightings := []struct {
+		latitude, longitude float32
+		category            models.RobotCategory
+		seenAt              time.Time
+		count               int
+	}{
+		{10.2, 20.7, models.RobotCategoryLand, now, 2},
+		{10.9, 20.1, models.RobotCategoryFlying, now, 3},
+		// the negative coordinates are floored into the lower cell
+		{-0.5, -0.5, models.RobotCategoryLand, now, 1},
+		// outside of the time window
+		{10.5, 20.5, models.RobotCategoryLand, now.Add(-2 * time.Hour), 10},
+	}
+	for i, sighting := range sightings {
+		err := store.Sightings().New(models.RobotSighting{
+			ID:       string(rune('a' + i)),
+			Location: models.Location{Latitude: sighting.latitude, Longitude: sighting.longitude},
+			Category: sighting.category,
+			SeenAt:   sighting.seenAt,
+			Count:    sighting.count,
+		})
+		if err != nil {
+			t.Fatalf("unable to add the sighting: %v", err)
+		}
+	}
+
+	query := models.ThreatMapQuery{CellSize: 1, SeenFrom: now.Add(-time.Hour), SeenTo: now}
+	cells, err := store.Sightings().ThreatMap(query)
+	if err != nil {
+		t.Fatalf("threat map failed: %v", err)
+	}
+	want := []models.ThreatCell{
+		{Row: 10, Column: 20, MinLatitude: 10, MinLongitude: 20, MaxLatitude: 11, MaxLongitude: 21, Sightings: 2, Robots: 5},
+		{Row: -1, Column: -1, MinLatitude: -1, MinLongitude: -1, MaxLatitude: 0, MaxLongitude: 0, Sightings: 1, Robots: 1},
+	}
+	if len(cells) != len(want) {
+		t.Fatalf("got %d cells %v, want %v", len(cells), cells, want)
+	}
+	for i := range want {
+		if cells[i] != want[i] {
+			t.Errorf("cell %d = %+v, want %+v", i, cells[i], want[i])
+		}
+	}
+
+	// the category filter and the finer grid
+	query.Category = models.RobotCategoryLand
+	query.CellSize = 0.5
+	cells, err = store.Sightings().ThreatMap(query)
+	if err != nil {
+		t.Fatalf("threat map failed: %v", err)
+	}
+	if len(cells) != 2 || cells[0].Row != 20 || cells[0].Column != 41 || cells[0].Robots != 2 {
+		t.Errorf("land cells = %+v, want the 2 robots in row 20 column 41 first", cells)
+	}
+	if cells[0].MaxLatitude != 10.5 || cells[0].MaxLongitude != 21 {
+		t.Errorf("cell bounds = %+v, want up to 10.5 and 21", cells[0])
+	}
+}
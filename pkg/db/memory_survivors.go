@@ -0,0 +1,350 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memory survivor services
+type MemorySurvivorServices struct {
+	store *MemoryAdapter
+}
+
+// New survivor entry
+func (sr *MemorySurvivorServices) New(data models.Survivor) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	if _, ok := sr.store.survivors[data.ID]; ok {
+		return ErrSurvivorExists
+	}
+	sr.store.survivorOrder = append(sr.store.survivorOrder, data.ID)
+	survivor := cloneSurvivor(data)
+	sr.store.survivors[data.ID] = &survivor
+
+	// starting point of the escape track
+	if (data.Location != models.Location{}) {
+		sr.store.locationHistory = append(sr.store.locationHistory, models.LocationHistory{
+			ID:        data.ID,
+			Location:  data.Location,
+			Timestamp: time.Now().UTC(),
+		})
+	}
+	return nil
+}
+
+// fetch the survivor details
+func (sr *MemorySurvivorServices) GetSurvivor(id string) (*models.Survivor, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	entry, ok := sr.store.survivors[id]
+	if !ok {
+		return nil, nil
+	}
+	survivor := cloneSurvivor(*entry)
+	return &survivor, nil
+}
+
+// check survivor exists or not with the id
+func (sr *MemorySurvivorServices) CheckSurvivorExists(id string) (bool, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	_, ok := sr.store.survivors[id]
+	return ok, nil
+}
+
+// Update survivor entry
+func (sr *MemorySurvivorServices) Update(data models.Survivor) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	entry, ok := sr.store.survivors[data.ID]
+	if !ok {
+		return ErrSurvivorNotFound
+	}
+	// inventory of the infected survivors are locked
+	if data.Resources != nil && entry.ReportedCount >= InfectionMinimumReportCount {
+		return ErrInventoryLocked
+	}
+
+	// based on the input it will update the entries
+	if data.Name != "" {
+		entry.Name = data.Name
+	}
+	if data.Age > 0 {
+		entry.Age = data.Age
+	}
+	if (data.Location != models.Location{}) {
+		entry.Location = data.Location
+		sr.store.locationHistory = append(sr.store.locationHistory, models.LocationHistory{
+			ID:        data.ID,
+			Location:  data.Location,
+			Timestamp: time.Now().UTC(),
+		})
+	}
+	if data.Resources != nil {
+		entry.Resources = cloneResources(data.Resources)
+	}
+	return nil
+}
+
+// change the inventory quantities
+func (sr *MemorySurvivorServices) AdjustInventory(id string, changes models.Resources) (models.Resources, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	entry, ok := sr.store.survivors[id]
+	if !ok {
+		return nil, ErrSurvivorNotFound
+	}
+	if entry.ReportedCount >= InfectionMinimumReportCount {
+		return nil, ErrInventoryLocked
+	}
+	// validate the whole change before touching the inventory
+	for item, quantity := range changes {
+		if entry.Resources[item]+quantity < 0 {
+			return nil, ErrInsufficientStock
+		}
+	}
+	if entry.Resources == nil {
+		entry.Resources = models.Resources{}
+	}
+	for item, quantity := range changes {
+		entry.Resources[item] += quantity
+	}
+	return cloneResources(entry.Resources), nil
+}
+
+// move the locked inventory to the shelter pool
+func (sr *MemorySurvivorServices) RedistributeInventory(id string) (models.Resources, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	entry, ok := sr.store.survivors[id]
+	if !ok {
+		return nil, ErrSurvivorNotFound
+	}
+	if entry.ReportedCount < InfectionMinimumReportCount {
+		return nil, ErrInventoryNotLocked
+	}
+
+	moved := entry.Resources
+	for item, quantity := range moved {
+		if quantity > 0 {
+			sr.store.shelter[item] += quantity
+		}
+	}
+	entry.Resources = models.Resources{}
+	return moved, nil
+}
+
+// report the survivor as infected
+func (sr *MemorySurvivorServices) Infected(report models.InfectionReportRecord) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	entry, ok := sr.store.survivors[report.SurvivorID]
+	if !ok {
+		return ErrSurvivorNotFound
+	}
+	for _, reporter := range entry.ReportedBy {
+		if reporter == report.ReportedBy {
+			return ErrAlreadyReported
+		}
+	}
+	entry.ReportedCount++
+	entry.ReportedBy = append(entry.ReportedBy, report.ReportedBy)
+	sr.store.reports = append(sr.store.reports, report)
+	return nil
+}
+
+// retract an infection report
+func (sr *MemorySurvivorServices) RetractReport(id string, reporter string) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	entry, ok := sr.store.survivors[id]
+	if !ok {
+		return ErrSurvivorNotFound
+	}
+	remaining := make([]string, 0, len(entry.ReportedBy))
+	for _, reportedBy := range entry.ReportedBy {
+		if reportedBy != reporter {
+			remaining = append(remaining, reportedBy)
+		}
+	}
+	if len(remaining) == len(entry.ReportedBy) {
+		return ErrReportNotFound
+	}
+	entry.ReportedBy = remaining
+	entry.ReportedCount = len(remaining)
+	sr.store.removeReports(func(report models.InfectionReportRecord) bool {
+		return report.SurvivorID == id && report.ReportedBy == reporter
+	})
+	return nil
+}
+
+// insert new change location history
+func (sr *MemorySurvivorServices) NewLocationHistory(id string, location models.Location) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	sr.store.locationHistory = append(sr.store.locationHistory, models.LocationHistory{
+		ID:        id,
+		Location:  location,
+		Timestamp: time.Now().UTC(),
+	})
+	return nil
+}
+
+// location history of the survivor
+// the entries are appended in time order, so no sorting is needed
+func (sr *MemorySurvivorServices) GetLocationHistory(id string, from time.Time, to time.Time) ([]models.LocationHistory, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	var collected_data []models.LocationHistory
+	for _, entry := range sr.store.locationHistory {
+		if entry.ID != id ||
+			(!from.IsZero() && entry.Timestamp.Before(from)) ||
+			(!to.IsZero() && entry.Timestamp.After(to)) {
+			continue
+		}
+		collected_data = append(collected_data, entry)
+	}
+	return collected_data, nil
+}
+
+// prepare infection report
+func (sr *MemorySurvivorServices) InfectedCount() (int, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	count := 0
+	for _, entry := range sr.store.survivors {
+		if entry.ReportedCount >= InfectionMinimumReportCount {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Count total survivors
+func (sr *MemorySurvivorServices) TotalSurvivors() (int, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	return len(sr.store.survivors), nil
+}
+
+// list infected survivors
+func (sr *MemorySurvivorServices) GetSurvivors(criteria string) ([]models.Survivor, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	var collected_data []models.Survivor
+	for _, id := range sr.store.survivorOrder {
+		entry := sr.store.survivors[id]
+		infected := entry.ReportedCount >= InfectionMinimumReportCount
+		if infected == (criteria == "infected") {
+			collected_data = append(collected_data, cloneSurvivor(*entry))
+		}
+	}
+	return collected_data, nil
+}
+
+// filtered, sorted and paginated survivors listing
+func (sr *MemorySurvivorServices) ListSurvivors(query models.SurvivorQuery) (*models.SurvivorPage, error) {
+	var cursorValue interface{}
+	var cursorID string
+	if query.Cursor != "" {
+		var err error
+		cursorValue, cursorID, err = decodeSurvivorCursor(query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	order := 1
+	if query.Order == "desc" {
+		order = -1
+	}
+	// position of the survivor against the cursor on the sort order
+	compare := func(sr models.Survivor, value interface{}, id string) int {
+		result := compareSortValues(survivorSortValue(sr, query.SortBy), value)
+		if result == 0 {
+			result = strings.Compare(sr.ID, id)
+		}
+		return result * order
+	}
+
+	sr.store.mu.RLock()
+	survivors := make([]models.Survivor, 0, len(sr.store.survivors))
+	for _, id := range sr.store.survivorOrder {
+		entry := *sr.store.survivors[id]
+		infected := entry.ReportedCount >= InfectionMinimumReportCount
+		switch {
+		case query.Status == "infected" && !infected,
+			query.Status == "non-infected" && infected,
+			query.MinAge > 0 && entry.Age < query.MinAge,
+			query.MaxAge > 0 && entry.Age > query.MaxAge,
+			query.Resource != "" && entry.Resources[query.Resource] <= 0,
+			query.Cursor != "" && compare(entry, cursorValue, cursorID) <= 0:
+			continue
+		}
+		survivors = append(survivors, cloneSurvivor(entry))
+	}
+	sr.store.mu.RUnlock()
+
+	sort.Slice(survivors, func(i, j int) bool {
+		return compare(survivors[i], survivorSortValue(survivors[j], query.SortBy), survivors[j].ID) < 0
+	})
+
+	page := &models.SurvivorPage{Survivors: survivors}
+	if len(survivors) > query.Limit {
+		page.Survivors = survivors[:query.Limit]
+		var err error
+		page.NextCursor, err = encodeSurvivorCursor(page.Survivors[query.Limit-1], query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// survivors near the point
+func (sr *MemorySurvivorServices) Nearby(query models.NearbyQuery) ([]models.NearbySurvivor, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	point := models.Location{Latitude: float32(*query.Latitude), Longitude: float32(*query.Longitude)}
+	collected_data := []models.NearbySurvivor{}
+	for _, id := range sr.store.survivorOrder {
+		entry := sr.store.survivors[id]
+		infected := entry.ReportedCount >= InfectionMinimumReportCount
+		if (query.Status == "infected" && !infected) || (query.Status == "non-infected" && infected) {
+			continue
+		}
+		// the survivors without a location are not indexed by mongo either
+		if entry.Location.IsZero() {
+			continue
+		}
+		if dist := Distance(point, entry.Location); dist <= query.Radius {
+			collected_data = append(collected_data, models.NearbySurvivor{
+				Survivor: cloneSurvivor(*entry),
+				Distance: dist,
+			})
+		}
+	}
+
+	sort.SliceStable(collected_data, func(i, j int) bool {
+		return collected_data[i].Distance < collected_data[j].Distance
+	})
+	if len(collected_data) > query.Limit {
+		collected_data = collected_data[:query.Limit]
+	}
+	return collected_data, nil
+}
@@ -0,0 +1,352 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+// new memory adapter with the survivors
+func newTestStore(t *testing.T, survivors ...models.Survivor) *MemoryAdapter {
+	t.Helper()
+	store := NewMemoryAdapter()
+	for _, survivor := range survivors {
+		if err := store.Survivors().New(survivor); err != nil {
+			t.Fatalf("unable to add survivor %s: %v", survivor.ID, err)
+		}
+	}
+	return store
+}
+
+// inventory of the survivor
+func inventory(t *testing.T, store *MemoryAdapter, id string) models.Resources {
+	t.Helper()
+	survivor, err := store.Survivors().GetSurvivor(id)
+	if err != nil || survivor == nil {
+		t.Fatalf("unable to fetch survivor %s: %v", id, err)
+	}
+	return survivor.Resources
+}
+
+// infection report of the reporter
+func newTestReport(survivorID string, reporter string) models.InfectionReportRecord {
+	return models.InfectionReportRecord{SurvivorID: survivorID, ReportedBy: reporter, CreatedAt: time.Now().UTC()}
+}
+
+func TestMemorySurvivors(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1", Name: "survivor1", Age: 16})
+
+	if exists, err := store.Survivors().CheckSurvivorExists("srv1"); err != nil || !exists {
+		t.Errorf("srv1 exists = %v, %v, want true", exists, err)
+	}
+	if survivor, err := store.Survivors().GetSurvivor("unknown"); err != nil || survivor != nil {
+		t.Errorf("unknown survivor = %+v, %v, want nil", survivor, err)
+	}
+	if err := store.Survivors().New(models.Survivor{ID: "srv1", Name: "survivor2"}); !errors.Is(err, ErrSurvivorExists) {
+		t.Errorf("existing id: error = %v, want %v", err, ErrSurvivorExists)
+	}
+
+	// only the given fields are updated
+	location := models.Location{Latitude: 10, Longitude: 20}
+	if err := store.Survivors().Update(models.Survivor{ID: "srv1", Age: 17, Location: location}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	survivor, err := store.Survivors().GetSurvivor("srv1")
+	if err != nil || survivor == nil {
+		t.Fatalf("unable to fetch srv1: %v", err)
+	}
+	if survivor.Name != "survivor1" || survivor.Age != 17 || survivor.Location != location {
+		t.Errorf("updated survivor = %+v, want survivor1, 17 at %+v", survivor, location)
+	}
+}
+
+func TestLocationHistory(t *testing.T) {
+	start := models.Location{Latitude: 10, Longitude: 20}
+	store := newTestStore(t, models.Survivor{ID: "srv1", Location: start}, models.Survivor{ID: "srv2"})
+
+	moved := models.Location{Latitude: 11, Longitude: 21}
+	if err := store.Survivors().Update(models.Survivor{ID: "srv1", Location: moved}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	track, err := store.Survivors().GetLocationHistory("srv1", time.Time{}, time.Time{})
+	if err != nil || len(track) != 2 || track[0].Location != start || track[1].Location != moved {
+		t.Fatalf("track = %+v, %v, want the start and the moved location", track, err)
+	}
+	// the survivor without a location has no track
+	if track, err := store.Survivors().GetLocationHistory("srv2", time.Time{}, time.Time{}); err != nil || len(track) != 0 {
+		t.Errorf("track of srv2 = %+v, %v, want it empty", track, err)
+	}
+
+	// the window keeps the entries between from and to
+	if track, _ := store.Survivors().GetLocationHistory("srv1", time.Now().Add(time.Hour), time.Time{}); len(track) != 0 {
+		t.Errorf("track after an hour = %+v, want it empty", track)
+	}
+	if track, _ := store.Survivors().GetLocationHistory("srv1", time.Time{}, time.Now().Add(time.Hour)); len(track) != 2 {
+		t.Errorf("track until an hour later = %+v, want both entries", track)
+	}
+}
+
+func TestNearbySurvivors(t *testing.T) {
+	store := newTestStore(t,
+		models.Survivor{ID: "far", Location: models.Location{Latitude: 10.1, Longitude: 20}},
+		models.Survivor{ID: "near", Location: models.Location{Latitude: 10.01, Longitude: 20}},
+		models.Survivor{ID: "infected", Location: models.Location{Latitude: 10, Longitude: 20}, ReportedCount: InfectionMinimumReportCount},
+		models.Survivor{ID: "away", Location: models.Location{Latitude: 50, Longitude: 20}},
+	)
+	latitude, longitude := 10.0, 20.0
+
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{"", []string{"infected", "near", "far"}},
+		{"non-infected", []string{"near", "far"}},
+		{"infected", []string{"infected"}},
+	}
+	for _, test := range tests {
+		nearby, err := store.Survivors().Nearby(models.NearbyQuery{
+			Latitude: &latitude, Longitude: &longitude, Radius: 20000, Status: test.status, Limit: 10,
+		})
+		if err != nil {
+			t.Fatalf("status %q: nearby failed: %v", test.status, err)
+		}
+		var got []string
+		for _, survivor := range nearby {
+			got = append(got, survivor.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("status %q: nearby = %v, want %v", test.status, got, test.want)
+		}
+	}
+}
+
+func TestNearbySkipsMissingLocations(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "unknown location"})
+	latitude, longitude := 0.0, 0.0
+
+	nearby, err := store.Survivors().Nearby(models.NearbyQuery{
+		Latitude: &latitude, Longitude: &longitude, Radius: 20000, Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("nearby failed: %v", err)
+	}
+	if len(nearby) != 0 {
+		t.Errorf("nearby = %+v, want no survivors", nearby)
+	}
+}
+
+func TestMemoryInfectionReports(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1"}, models.Survivor{ID: "srv2"})
+
+	for i := 0; i < InfectionMinimumReportCount; i++ {
+		if err := store.Survivors().Infected(newTestReport("srv1", fmt.Sprintf("reporter%d", i))); err != nil {
+			t.Fatalf("infection report failed: %v", err)
+		}
+	}
+
+	infected, err := store.Survivors().InfectedCount()
+	if err != nil || infected != 1 {
+		t.Errorf("infected count = %d, %v, want 1", infected, err)
+	}
+	total, err := store.Survivors().TotalSurvivors()
+	if err != nil || total != 2 {
+		t.Errorf("total survivors = %d, %v, want 2", total, err)
+	}
+	for criteria, want := range map[string]string{"infected": "srv1", "non-infected": "srv2"} {
+		survivors, err := store.Survivors().GetSurvivors(criteria)
+		if err != nil || len(survivors) != 1 || survivors[0].ID != want {
+			t.Errorf("%s survivors = %+v, %v, want only %s", criteria, survivors, err, want)
+		}
+	}
+}
+
+func TestInfectionReportedOnce(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1"})
+
+	if err := store.Survivors().Infected(newTestReport("srv1", "srv2")); err != nil {
+		t.Fatalf("infection report failed: %v", err)
+	}
+	if err := store.Survivors().Infected(newTestReport("srv1", "srv2")); !errors.Is(err, ErrAlreadyReported) {
+		t.Errorf("repeated report: error = %v, want %v", err, ErrAlreadyReported)
+	}
+	if err := store.Survivors().Infected(newTestReport("unknown", "srv2")); !errors.Is(err, ErrSurvivorNotFound) {
+		t.Errorf("unknown survivor: error = %v, want %v", err, ErrSurvivorNotFound)
+	}
+	if survivor, _ := store.Survivors().GetSurvivor("srv1"); survivor == nil || survivor.ReportedCount != 1 {
+		t.Errorf("survivor = %+v, want the report counted once", survivor)
+	}
+}
+
+func TestRetractReport(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1"})
+	for _, reporter := range []string{"srv2", "srv3"} {
+		if err := store.Survivors().Infected(newTestReport("srv1", reporter)); err != nil {
+			t.Fatalf("infection report failed: %v", err)
+		}
+	}
+
+	if err := store.Survivors().RetractReport("srv1", "srv2"); err != nil {
+		t.Fatalf("retract failed: %v", err)
+	}
+	if err := store.Survivors().RetractReport("srv1", "srv2"); !errors.Is(err, ErrReportNotFound) {
+		t.Errorf("second retract: error = %v, want %v", err, ErrReportNotFound)
+	}
+	if survivor, _ := store.Survivors().GetSurvivor("srv1"); survivor == nil || survivor.ReportedCount != 1 {
+		t.Errorf("survivor = %+v, want the report of srv3 only", survivor)
+	}
+	// the reporter can report again after the retraction
+	if err := store.Survivors().Infected(newTestReport("srv1", "srv2")); err != nil {
+		t.Errorf("report after the retraction failed: %v", err)
+	}
+}
+
+func TestInfectionReportRecords(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1"})
+	for _, reporter := range []string{"srv2", "srv3"} {
+		if err := store.Survivors().Infected(newTestReport("srv1", reporter)); err != nil {
+			t.Fatalf("infection report failed: %v", err)
+		}
+	}
+	// the rejected repeated report leaves no record behind
+	if err := store.Survivors().Infected(newTestReport("srv1", "srv2")); !errors.Is(err, ErrAlreadyReported) {
+		t.Fatalf("repeated report: error = %v, want %v", err, ErrAlreadyReported)
+	}
+	if reports, err := store.Reports().List("srv1"); err != nil || len(reports) != 2 {
+		t.Errorf("reports = %+v, %v, want 2 records", reports, err)
+	}
+
+	if err := store.Survivors().RetractReport("srv1", "srv2"); err != nil {
+		t.Fatalf("retract failed: %v", err)
+	}
+	reports, err := store.Reports().List("srv1")
+	if err != nil || len(reports) != 1 || reports[0].ReportedBy != "srv3" {
+		t.Errorf("reports = %+v, %v, want only the report of srv3", reports, err)
+	}
+}
+
+func TestAdjustInventory(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "srv1", Resources: models.Resources{models.Water: 2}})
+
+	updated, err := store.Survivors().AdjustInventory("srv1", models.Resources{models.Water: -1, models.Food: 3})
+	if err != nil {
+		t.Fatalf("adjustment failed: %v", err)
+	}
+	if updated[models.Water] != 1 || updated[models.Food] != 3 {
+		t.Errorf("updated inventory = %v, want 1 water and 3 food", updated)
+	}
+
+	// the whole change is rejected when an item would go negative
+	_, err = store.Survivors().AdjustInventory("srv1", models.Resources{models.Food: 1, models.Water: -2})
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("error = %v, want %v", err, ErrInsufficientStock)
+	}
+	if got := inventory(t, store, "srv1"); got[models.Water] != 1 || got[models.Food] != 3 {
+		t.Errorf("inventory = %v, want the unchanged 1 water and 3 food", got)
+	}
+
+	if _, err := store.Survivors().AdjustInventory("unknown", models.Resources{models.Food: 1}); !errors.Is(err, ErrSurvivorNotFound) {
+		t.Errorf("unknown survivor: error = %v, want %v", err, ErrSurvivorNotFound)
+	}
+	if err := store.Survivors().Update(models.Survivor{ID: "unknown", Resources: models.Resources{}}); !errors.Is(err, ErrSurvivorNotFound) {
+		t.Errorf("update of unknown survivor: error = %v, want %v", err, ErrSurvivorNotFound)
+	}
+}
+
+func TestInventoryLocked(t *testing.T) {
+	store := newTestStore(t, models.Survivor{ID: "infected", Resources: models.Resources{models.Water: 2}, ReportedCount: InfectionMinimumReportCount})
+
+	if _, err := store.Survivors().AdjustInventory("infected", models.Resources{models.Water: -1}); !errors.Is(err, ErrInventoryLocked) {
+		t.Errorf("adjustment error = %v, want %v", err, ErrInventoryLocked)
+	}
+	err := store.Survivors().Update(models.Survivor{ID: "infected", Resources: models.Resources{models.Food: 1}})
+	if !errors.Is(err, ErrInventoryLocked) {
+		t.Errorf("update error = %v, want %v", err, ErrInventoryLocked)
+	}
+	if got := inventory(t, store, "infected"); got[models.Water] != 2 || got[models.Food] != 0 {
+		t.Errorf("inventory = %v, want the unchanged 2 water", got)
+	}
+}
+
+func TestRedistributeInventory(t *testing.T) {
+	store := newTestStore(t,
+		models.Survivor{ID: "infected", Resources: models.Resources{models.Water: 2, models.Food: 1}, ReportedCount: InfectionMinimumReportCount},
+		models.Survivor{ID: "healthy", Resources: models.Resources{models.Water: 1}},
+	)
+
+	if _, err := store.Survivors().RedistributeInventory("healthy"); !errors.Is(err, ErrInventoryNotLocked) {
+		t.Errorf("healthy survivor: error = %v, want %v", err, ErrInventoryNotLocked)
+	}
+	moved, err := store.Survivors().RedistributeInventory("infected")
+	if err != nil {
+		t.Fatalf("redistribution failed: %v", err)
+	}
+	if moved[models.Water] != 2 || moved[models.Food] != 1 {
+		t.Errorf("moved items = %v, want 2 water and 1 food", moved)
+	}
+	if got := inventory(t, store, "infected"); len(got) != 0 {
+		t.Errorf("inventory of infected = %v, want it empty", got)
+	}
+	shelter, err := store.Shelter().Inventory()
+	if err != nil || shelter[models.Water] != 2 || shelter[models.Food] != 1 {
+		t.Errorf("shelter = %v, %v, want 2 water and 1 food", shelter, err)
+	}
+}
+
+func TestListSurvivorsPagination(t *testing.T) {
+	created := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	var survivors []models.Survivor
+	for i := 0; i < 7; i++ {
+		// the ages repeat, so the id breaks the ties
+		survivors = append(survivors, models.Survivor{
+			ID:        fmt.Sprintf("srv%d", i),
+			Age:       20 + i%3,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	store := newTestStore(t, survivors...)
+
+	for _, order := range []string{"asc", "desc"} {
+		t.Run(order, func(t *testing.T) {
+			query := models.SurvivorQuery{SortBy: "age", Order: order, Limit: 3}
+			var listed []models.Survivor
+			for pages := 0; ; pages++ {
+				if pages > len(survivors) {
+					t.Fatal("pagination doesn't finish")
+				}
+				page, err := store.Survivors().ListSurvivors(query)
+				if err != nil {
+					t.Fatalf("listing failed: %v", err)
+				}
+				listed = append(listed, page.Survivors...)
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			if len(listed) != len(survivors) {
+				t.Fatalf("listed %d survivors, want %d", len(listed), len(survivors))
+			}
+			seen := map[string]bool{}
+			for i, survivor := range listed {
+				if seen[survivor.ID] {
+					t.Errorf("survivor %s is listed twice", survivor.ID)
+				}
+				seen[survivor.ID] = true
+				if i == 0 {
+					continue
+				}
+				previous := listed[i-1]
+				result := compareSortValues(previous.Age, survivor.Age)
+				if result == 0 {
+					result = compareSortValues(previous.ID, survivor.ID)
+				}
+				if (order == "asc" && result > 0) || (order == "desc" && result < 0) {
+					t.Errorf("%s (age %d) is listed after %s (age %d)", survivor.ID, survivor.Age, previous.ID, previous.Age)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// memory trade services
+type MemoryTradeServices struct {
+	store *MemoryAdapter
+}
+
+// New trade proposal entry
+func (sr *MemoryTradeServices) New(data models.TradeProposal) error {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	sr.store.trades = append(sr.store.trades, data)
+	return nil
+}
+
+// fetch the trade proposal details
+func (sr *MemoryTradeServices) Get(id string) (*models.TradeProposal, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	for _, proposal := range sr.store.trades {
+		if proposal.ID == id {
+			return &proposal, nil
+		}
+	}
+	return nil, nil
+}
+
+// list trade proposals of the survivor
+func (sr *MemoryTradeServices) List(survivorID string, status models.TradeStatus) ([]models.TradeProposal, error) {
+	sr.store.mu.RLock()
+	defer sr.store.mu.RUnlock()
+
+	collected_data := []models.TradeProposal{}
+	// the proposals are stored in the creation order
+	for i := len(sr.store.trades) - 1; i >= 0; i-- {
+		proposal := sr.store.trades[i]
+		if proposal.From.ID != survivorID && proposal.To.ID != survivorID {
+			continue
+		}
+		if status == "" || proposal.Status == status {
+			collected_data = append(collected_data, proposal)
+		}
+	}
+	return collected_data, nil
+}
+
+// resolve a pending trade proposal
+// both survivors are validated before touching the inventories
+func (sr *MemoryTradeServices) Resolve(id string, status models.TradeStatus) (*models.TradeProposal, error) {
+	sr.store.mu.Lock()
+	defer sr.store.mu.Unlock()
+
+	for i := range sr.store.trades {
+		proposal := &sr.store.trades[i]
+		if proposal.ID != id {
+			continue
+		}
+		if proposal.Status != models.TradePending {
+			return nil, ErrTradeResolved
+		}
+		if status == models.TradeAccepted {
+			if err := sr.store.exchange(proposal.From, proposal.To); err != nil {
+				return nil, err
+			}
+		}
+		resolvedAt := time.Now().UTC()
+		proposal.Status = status
+		proposal.ResolvedAt = &resolvedAt
+		resolved := *proposal
+		return &resolved, nil
+	}
+	return nil, ErrTradeNotFound
+}
+
+// swap the offered items between the survivors, the caller holds the lock
+func (adptr *MemoryAdapter) exchange(from models.TradeOffer, to models.TradeOffer) error {
+	parties := []struct {
+		offer    models.TradeOffer
+		received models.Resources
+	}{
+		{from, to.Items},
+		{to, from.Items},
+	}
+	for _, party := range parties {
+		entry, ok := adptr.survivors[party.offer.ID]
+		if !ok {
+			return ErrSurvivorNotFound
+		}
+		if entry.ReportedCount >= InfectionMinimumReportCount {
+			return ErrInventoryLocked
+		}
+		for item, quantity := range party.offer.Items {
+			if entry.Resources[item] < quantity {
+				return ErrInsufficientStock
+			}
+		}
+	}
+
+	for _, party := range parties {
+		entry := adptr.survivors[party.offer.ID]
+		if entry.Resources == nil {
+			entry.Resources = models.Resources{}
+		}
+		for item, quantity := range party.offer.Items {
+			entry.Resources[item] -= quantity
+		}
+		for item, quantity := range party.received {
+			entry.Resources[item] += quantity
+		}
+	}
+	return nil
+}
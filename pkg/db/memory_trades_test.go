@@ -0,0 +1,159 @@
+package db
+
+import (
+	"errors"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+// pending trade proposal between the survivors
+func newTestTrade(id string, from models.TradeOffer, to models.TradeOffer) models.TradeProposal {
+	return models.TradeProposal{ID: id, From: from, To: to, Status: models.TradePending, CreatedAt: time.Now().UTC()}
+}
+
+func TestTradeMovesItems(t *testing.T) {
+	store := newTestStore(t,
+		models.Survivor{ID: "a", Resources: models.Resources{models.Water: 2}},
+		models.Survivor{ID: "b", Resources: models.Resources{models.Food: 3}},
+	)
+
+	err := store.Trades().New(newTestTrade("t1",
+		models.TradeOffer{ID: "a", Items: models.Resources{models.Water: 1}},
+		models.TradeOffer{ID: "b", Items: models.Resources{models.Food: 3}},
+	))
+	if err != nil {
+		t.Fatalf("trade proposal failed: %v", err)
+	}
+	// the proposal alone doesn't touch the inventories
+	if got := inventory(t, store, "a"); got[models.Water] != 2 || got[models.Food] != 0 {
+		t.Errorf("inventory of a before the acceptance = %v, want the original 2 water", got)
+	}
+
+	proposal, err := store.Trades().Resolve("t1", models.TradeAccepted)
+	if err != nil {
+		t.Fatalf("trade failed: %v", err)
+	}
+	if proposal.Status != models.TradeAccepted || proposal.ResolvedAt == nil {
+		t.Errorf("proposal = %+v, want accepted with the resolved time", proposal)
+	}
+	if got := inventory(t, store, "a"); got[models.Water] != 1 || got[models.Food] != 3 {
+		t.Errorf("inventory of a = %v, want 1 water and 3 food", got)
+	}
+	if got := inventory(t, store, "b"); got[models.Water] != 1 || got[models.Food] != 0 {
+		t.Errorf("inventory of b = %v, want 1 water and no food", got)
+	}
+
+	if _, err := store.Trades().Resolve("t1", models.TradeAccepted); !errors.Is(err, ErrTradeResolved) {
+		t.Errorf("second acceptance: error = %v, want %v", err, ErrTradeResolved)
+	}
+	if _, err := store.Trades().Resolve("unknown", models.TradeDeclined); !errors.Is(err, ErrTradeNotFound) {
+		t.Errorf("unknown proposal: error = %v, want %v", err, ErrTradeNotFound)
+	}
+}
+
+func TestTradeIsAtomic(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   models.TradeOffer
+		to     models.TradeOffer
+		reason error
+	}{
+		{
+			name:   "insufficient stock on the receiving side",
+			from:   models.TradeOffer{ID: "a", Items: models.Resources{models.Water: 2}},
+			to:     models.TradeOffer{ID: "b", Items: models.Resources{models.Food: 4}},
+			reason: ErrInsufficientStock,
+		},
+		{
+			name:   "infected receiving side",
+			from:   models.TradeOffer{ID: "a", Items: models.Resources{models.Water: 2}},
+			to:     models.TradeOffer{ID: "infected", Items: models.Resources{models.Food: 1}},
+			reason: ErrInventoryLocked,
+		},
+		{
+			name:   "unknown receiving side",
+			from:   models.TradeOffer{ID: "a", Items: models.Resources{models.Water: 2}},
+			to:     models.TradeOffer{ID: "unknown", Items: models.Resources{models.Food: 1}},
+			reason: ErrSurvivorNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newTestStore(t,
+				models.Survivor{ID: "a", Resources: models.Resources{models.Water: 2}},
+				models.Survivor{ID: "b", Resources: models.Resources{models.Food: 3}},
+				models.Survivor{ID: "infected", Resources: models.Resources{models.Food: 3}, ReportedCount: InfectionMinimumReportCount},
+			)
+			if err := store.Trades().New(newTestTrade("t1", test.from, test.to)); err != nil {
+				t.Fatalf("trade proposal failed: %v", err)
+			}
+
+			_, err := store.Trades().Resolve("t1", models.TradeAccepted)
+			if !errors.Is(err, test.reason) {
+				t.Fatalf("trade error = %v, want %v", err, test.reason)
+			}
+			// neither side is changed and the proposal stays pending
+			if got := inventory(t, store, "a"); got[models.Water] != 2 || got[models.Food] != 0 {
+				t.Errorf("inventory of a = %v, want the original 2 water", got)
+			}
+			if got := inventory(t, store, "b"); got[models.Water] != 0 || got[models.Food] != 3 {
+				t.Errorf("inventory of b = %v, want the original 3 food", got)
+			}
+			if got := inventory(t, store, "infected"); got[models.Water] != 0 || got[models.Food] != 3 {
+				t.Errorf("inventory of infected = %v, want the original 3 food", got)
+			}
+			if proposal, _ := store.Trades().Get("t1"); proposal == nil || proposal.Status != models.TradePending {
+				t.Errorf("proposal = %+v, want pending", proposal)
+			}
+		})
+	}
+}
+
+func TestTradeList(t *testing.T) {
+	store := newTestStore(t)
+	offer := func(id string) models.TradeOffer {
+		return models.TradeOffer{ID: id, Items: models.Resources{models.Water: 1}}
+	}
+	for _, proposal := range []models.TradeProposal{
+		newTestTrade("t1", offer("a"), offer("b")),
+		newTestTrade("t2", offer("c"), offer("a")),
+		newTestTrade("t3", offer("b"), offer("c")),
+	} {
+		if err := store.Trades().New(proposal); err != nil {
+			t.Fatalf("trade proposal failed: %v", err)
+		}
+	}
+	if _, err := store.Trades().Resolve("t1", models.TradeDeclined); err != nil {
+		t.Fatalf("decline failed: %v", err)
+	}
+
+	tests := []struct {
+		status models.TradeStatus
+		want   []string
+	}{
+		{"", []string{"t2", "t1"}},
+		{models.TradePending, []string{"t2"}},
+		{models.TradeAccepted, []string{}},
+	}
+	for _, test := range tests {
+		trades, err := store.Trades().List("a", test.status)
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		var got []string
+		for _, proposal := range trades {
+			got = append(got, proposal.ID)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("status %q: trades = %v, want %v", test.status, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("status %q: trades = %v, want %v", test.status, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"robot-apocalypse/pkg/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration of the documents written by the older versions
+type migration struct {
+	name       string
+	collection string
+	run        func(ctx context.Context, collection *mongo.Collection) error
+}
+
+// the migrations are applied in order before the indexes are created, every
+// migration only matches the documents still in the legacy shape, so they
+// are safe to run on every start
+var migrations = []migration{
+	{"legacy resources", "survivors", migrateLegacyResources},
+	{"legacy locations", "survivors", migrateLegacyLocations},
+	{"missing locations", "survivors", migrateMissingLocations},
+	{"legacy created times", "survivors", migrateLegacyCreatedAt},
+	{"legacy roles", "api_keys", migrateLegacyAPIKeyRoles},
+	{"duplicate serial numbers", "robots", migrateDuplicateRobotSerials},
+}
+
+// apply the migrations of the legacy documents
+func (adptr *MongoAdapter) migrate(ctx context.Context) error {
+	for _, entry := range migrations {
+		if err := entry.run(ctx, adptr.ConnectCollection(entry.collection)); err != nil {
+			return fmt.Errorf("unable to migrate the %s of %s: %v", entry.name, entry.collection, err)
+		}
+	}
+	return nil
+}
+
+// rewrite the inventories stored as an array of item names to the quantity
+// of each item
+func migrateLegacyResources(ctx context.Context, collection *mongo.Collection) error {
+	legacy := bson.M{"resources": bson.M{"$type": "array"}}
+	cursor, err := collection.Find(ctx, legacy, options.Find().SetProjection(bson.M{"resources": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document struct {
+			ID        interface{}      `bson:"_id"`
+			Resources models.Resources `bson:"resources"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"_id": document.ID, "resources": bson.M{"$type": "array"}},
+			bson.M{"$set": bson.M{"resources": document.Resources}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// rewrite the latitude/longitude locations to GeoJSON points, the 2dsphere
+// index can't be built on them
+// the out of range locations are moved to invalidlocation, so they can be
+// looked up and corrected, the survivors are left without a location
+func migrateLegacyLocations(ctx context.Context, collection *mongo.Collection) error {
+	legacy := bson.M{"location": bson.M{"$type": "object"}, "location.type": bson.M{"$exists": false}}
+	cursor, err := collection.Find(ctx, legacy, options.Find().SetProjection(bson.M{"location": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document struct {
+			ID       interface{} `bson:"_id"`
+			Location bson.Raw    `bson:"location"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		var location struct {
+			Latitude  *float64 `bson:"latitude"`
+			Longitude *float64 `bson:"longitude"`
+		}
+		if err := bson.Unmarshal(document.Location, &location); err != nil {
+			return err
+		}
+
+		update := bson.M{
+			"$set":   bson.M{"invalidlocation": document.Location},
+			"$unset": bson.M{"location": ""},
+		}
+		if validLocation(location.Latitude, location.Longitude) {
+			update = bson.M{"$set": bson.M{"location": models.Location{
+				Latitude:  float32(*location.Latitude),
+				Longitude: float32(*location.Longitude),
+			}}}
+		}
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": document.ID, "location.type": bson.M{"$exists": false}}, update)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// remove the 0,0 points stored for the survivors registered without a
+// location, they were found by the nearby queries around 0,0
+func migrateMissingLocations(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"location.type": models.GeoJSONPoint, "location.coordinates": bson.A{0.0, 0.0}},
+		bson.M{"$unset": bson.M{"location": ""}},
+	)
+	return err
+}
+
+// set the created time of the survivors stored before it was kept, the
+// cursor pagination skips the survivors without a created time
+// the time is taken from the ObjectID of the document
+func migrateLegacyCreatedAt(ctx context.Context, collection *mongo.Collection) error {
+	legacy := bson.M{"createdat": bson.M{"$exists": false}}
+	cursor, err := collection.Find(ctx, legacy, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var document struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return err
+		}
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"_id": document.ID, "createdat": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"createdat": legacyCreatedAt(document.ID)}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// created time of a legacy document, the documents inserted with a custom
+// _id are put before every other survivor
+func legacyCreatedAt(id interface{}) time.Time {
+	if objectID, ok := id.(primitive.ObjectID); ok {
+		return objectID.Timestamp().UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// give a role to the api keys created before the roles, the keys with the
+// admin scope are admins and the others are field officers, every request
+// of a key without a role is forbidden
+// the survivor role can't be given, the survivor of the key is not known
+func migrateLegacyAPIKeyRoles(ctx context.Context, collection *mongo.Collection) error {
+	// a null role also matches the keys without the role field
+	legacy := bson.M{"$in": bson.A{nil, ""}}
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"role": legacy, "scopes": models.ScopeAdmin},
+		bson.M{"$set": bson.M{"role": models.RoleAdmin}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateMany(ctx,
+		bson.M{"role": legacy},
+		bson.M{"$set": bson.M{"role": models.RoleFieldOfficer}},
+	)
+	return err
+}
+
+// the robots loaded before the upserts may share the serial numbers, the
+// unique serial number index can't be built on them
+// the robots are only a copy of the feed, so the latest inserted robot of
+// each serial number is kept and the next sync corrects it
+func migrateDuplicateRobotSerials(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": -1}}},
+		{{Key: "$group", Value: bson.M{"_id": "$serialnumber", "ids": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var duplicate struct {
+			IDs []interface{} `bson:"ids"`
+		}
+		if err := cursor.Decode(&duplicate); err != nil {
+			return err
+		}
+		_, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicate.IDs[1:]}})
+		if err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	// the older versions created the same index without the uniqueness, it
+	// has to be dropped before the unique one is created
+	specs, err := collection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec.Name == "serialnumber_1" && (spec.Unique == nil || !*spec.Unique) {
+			if _, err := collection.Indexes().DropOne(ctx, spec.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// coordinates of a legacy location are present and in range
+func validLocation(latitude, longitude *float64) bool {
+	return latitude != nil && longitude != nil &&
+		*latitude >= -90 && *latitude <= 90 &&
+		*longitude >= -180 && *longitude <= 180
+}
+
+// the survivors stored by the older versions may share the same id, the
+// unique id index can't be built on them
+// the duplicates are left to the operator, the survivor to keep can't be
+// decided here
+func checkDuplicateSurvivorIDs(ctx context.Context, collection *mongo.Collection) error {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$limit", Value: 10}},
+	})
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		ids = append(ids, fmt.Sprintf("%q", duplicate.ID))
+	}
+	return fmt.Errorf("the unique survivor id index can't be created, the ids %s are shared by more than one survivor: "+
+		"list them with db.survivors.find({ id: { $in: [%s] } }), keep one survivor of each id, "+
+		"remove the others with db.survivors.deleteOne({ _id: ObjectId(\"...\") }) and restart the server",
+		strings.Join(ids, ", "), strings.Join(ids, ", "))
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLegacyCreatedAt(t *testing.T) {
+	inserted := time.Date(2022, 4, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		id   interface{}
+		want time.Time
+	}{
+		{"object id", primitive.NewObjectIDFromTimestamp(inserted), inserted},
+		{"custom id", "srv1", time.Unix(0, 0).UTC()},
+	}
+	for _, test := range tests {
+		if got := legacyCreatedAt(test.id); !got.Equal(test.want) {
+			t.Errorf("%s: created time = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
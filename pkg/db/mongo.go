@@ -6,7 +6,9 @@ package db
 import (
 	"context"
 	"fmt"
+	"robot-apocalypse/pkg/models"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -16,28 +18,166 @@ import (
 type MongoAdapter struct {
 	client   *mongo.Client
 	database *mongo.Database
-
-	Services
 }
 
-// adapter services
-type Services interface {
-	Survivors() SurvivorServices
-	Robots() RobotsServices
-}
+var _ Services = (*MongoAdapter)(nil)
 
 // survivor service
-func (adptr *MongoAdapter) Survivors() *SurvivorServices {
-	srv := NewSurvivorServices()
+func (adptr *MongoAdapter) Survivors() SurvivorServices {
+	srv := NewMongoSurvivorServices()
+	srv.Client = adptr.client
 	srv.Collection = adptr.ConnectCollection("survivors")
 	srv.LocationHistory = adptr.ConnectCollection("survivors_location_history")
+	srv.Shelter = adptr.ConnectCollection("shelter_pool")
+	srv.Reports = adptr.ConnectCollection("infection_reports")
 	return srv
 }
 
-// survivor service
-func (adptr *MongoAdapter) Robots() *RobotsServices {
-	srv := NewRobotsServices()
+// robots service
+func (adptr *MongoAdapter) Robots() RobotsServices {
+	srv := NewMongoRobotsServices()
+	srv.Client = adptr.client
 	srv.Collection = adptr.ConnectCollection("robots")
+	srv.SyncLog = adptr.ConnectCollection("robot_sync_log")
+	return srv
+}
+
+// robot sighting service
+func (adptr *MongoAdapter) Sightings() SightingServices {
+	srv := NewMongoSightingServices()
+	srv.Collection = adptr.ConnectCollection("robot_sightings")
+	return srv
+}
+
+// shelter service
+func (adptr *MongoAdapter) Shelter() ShelterServices {
+	srv := NewMongoShelterServices()
+	srv.Collection = adptr.ConnectCollection("shelter_pool")
+	return srv
+}
+
+// appeal service
+func (adptr *MongoAdapter) Appeals() AppealServices {
+	srv := NewMongoAppealServices()
+	srv.Client = adptr.client
+	srv.Collection = adptr.ConnectCollection("infection_appeals")
+	srv.Survivors = adptr.ConnectCollection("survivors")
+	srv.Reports = adptr.ConnectCollection("infection_reports")
+	return srv
+}
+
+// trade service
+func (adptr *MongoAdapter) Trades() TradeServices {
+	srv := NewMongoTradeServices()
+	srv.Client = adptr.client
+	srv.Collection = adptr.ConnectCollection("trades")
+	srv.Survivors = adptr.ConnectCollection("survivors")
+	return srv
+}
+
+// infection report service
+func (adptr *MongoAdapter) Reports() InfectionReportServices {
+	srv := NewMongoInfectionReportServices()
+	srv.Collection = adptr.ConnectCollection("infection_reports")
+	return srv
+}
+
+// collection indexes
+// created at startup, creating an existing index is a no-op
+var collectionIndexes = []struct {
+	collection string
+	indexes    []mongo.IndexModel
+}{
+	{"survivors", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+	}},
+	{"survivors_location_history", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}, {Key: "timestamp", Value: 1}}},
+	}},
+	{"infection_reports", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "survivorid", Value: 1}, {Key: "createdat", Value: 1}}},
+	}},
+	// a survivor can only have one pending appeal
+	{"infection_appeals", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "survivorid", Value: 1}}, Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": models.AppealPending})},
+	}},
+	{"trades", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "from.id", Value: 1}, {Key: "createdat", Value: -1}}},
+		{Keys: bson.D{{Key: "to.id", Value: 1}, {Key: "createdat", Value: -1}}},
+	}},
+	{"idempotency_keys", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "createdat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(idempotencyKeyTTL.Seconds()))},
+	}},
+	// the upserts of the robots are matched by the serial number
+	{"robots", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "serialnumber", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "serialnumber", Value: 1}}},
+		{Keys: bson.D{{Key: "model", Value: 1}, {Key: "serialnumber", Value: 1}}},
+		{Keys: bson.D{{Key: "manufacturedat", Value: 1}, {Key: "serialnumber", Value: 1}}},
+	}},
+	{"robot_sightings", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "location", Value: "2dsphere"}, {Key: "seenat", Value: -1}}},
+		{Keys: bson.D{{Key: "seenat", Value: -1}}},
+		{Keys: bson.D{{Key: "serialnumber", Value: 1}, {Key: "seenat", Value: -1}}},
+	}},
+	{"robot_sync_log", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "startedat", Value: -1}}},
+	}},
+	{"survivor_credentials", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "survivorid", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}},
+	{"refresh_tokens", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}},
+	{"api_keys", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}},
+}
+
+// create the indexes of the collections
+// the legacy documents are migrated first, so the indexes can be built on them
+func (adptr *MongoAdapter) EnsureIndexes() error {
+	if err := adptr.migrate(context.TODO()); err != nil {
+		return err
+	}
+	if err := checkDuplicateSurvivorIDs(context.TODO(), adptr.ConnectCollection("survivors")); err != nil {
+		return err
+	}
+	for _, entry := range collectionIndexes {
+		_, err := adptr.ConnectCollection(entry.collection).Indexes().CreateMany(context.TODO(), entry.indexes)
+		if err != nil {
+			return fmt.Errorf("unable to create %s indexes: %v", entry.collection, err)
+		}
+	}
+	return nil
+}
+
+// idempotency service
+func (adptr *MongoAdapter) Idempotency() IdempotencyServices {
+	srv := NewMongoIdempotencyServices()
+	srv.Collection = adptr.ConnectCollection("idempotency_keys")
+	return srv
+}
+
+// api key service
+func (adptr *MongoAdapter) APIKeys() APIKeyServices {
+	srv := NewMongoAPIKeyServices()
+	srv.Collection = adptr.ConnectCollection("api_keys")
+	return srv
+}
+
+// credential service
+func (adptr *MongoAdapter) Credentials() CredentialServices {
+	srv := NewMongoCredentialServices()
+	srv.Collection = adptr.ConnectCollection("survivor_credentials")
+	srv.RefreshTokens = adptr.ConnectCollection("refresh_tokens")
 	return srv
 }
 
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"robot-apocalypse/pkg/models"
+	"strings"
+	"time"
+)
+
+// sortable survivor fields and the respective document field
+var survivorSortFields = map[string]string{
+	"name":    "name",
+	"age":     "age",
+	"created": "createdat",
+}
+
+// sortable robot fields and the respective document field
+var robotSortFields = map[string]string{
+	"serial":       "serialnumber",
+	"model":        "model",
+	"manufactured": "manufacturedat",
+}
+
+// listing cursor
+// position of the last entry of a page, the id (the serial number of the
+// robots) breaks the ties between the same sort values
+type pageCursor struct {
+	SortBy string          `json:"s"`
+	Order  string          `json:"o"`
+	Value  json.RawMessage `json:"v"`
+	ID     string          `json:"id"`
+}
+
+// sort value of the survivor for the sort field
+func survivorSortValue(sr models.Survivor, sortBy string) interface{} {
+	switch sortBy {
+	case "name":
+		return sr.Name
+	case "age":
+		return sr.Age
+	default:
+		return sr.CreatedAt
+	}
+}
+
+// encode the position of the survivor as an opaque cursor
+func encodeSurvivorCursor(sr models.Survivor, query models.SurvivorQuery) (string, error) {
+	value, err := json.Marshal(survivorSortValue(sr, query.SortBy))
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(pageCursor{
+		SortBy: query.SortBy,
+		Order:  query.Order,
+		Value:  value,
+		ID:     sr.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decode the cursor and return the typed sort value and the survivor id
+// the cursor is only valid for the same sorting it was created with
+func decodeSurvivorCursor(query models.SurvivorQuery) (interface{}, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	if cursor.SortBy != query.SortBy || cursor.Order != query.Order {
+		return nil, "", ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch query.SortBy {
+	case "name":
+		var name string
+		err = json.Unmarshal(cursor.Value, &name)
+		value = name
+	case "age":
+		var age int
+		err = json.Unmarshal(cursor.Value, &age)
+		value = age
+	default:
+		var created time.Time
+		err = json.Unmarshal(cursor.Value, &created)
+		value = created
+	}
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	return value, cursor.ID, nil
+}
+
+// sort value of the robot for the sort field
+func robotSortValue(robot models.RobotList, sortBy string) interface{} {
+	switch sortBy {
+	case "model":
+		return robot.Model
+	case "manufactured":
+		return robot.ManufacturedAt
+	default:
+		return robot.SerialNumber
+	}
+}
+
+// encode the position of the robot as an opaque cursor
+func encodeRobotCursor(robot models.RobotList, query models.RobotQuery) (string, error) {
+	value, err := json.Marshal(robotSortValue(robot, query.SortBy))
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(pageCursor{
+		SortBy: query.SortBy,
+		Order:  query.Order,
+		Value:  value,
+		ID:     robot.SerialNumber,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decode the cursor and return the typed sort value and the serial number
+// the cursor is only valid for the same sorting it was created with
+func decodeRobotCursor(query models.RobotQuery) (interface{}, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	if cursor.SortBy != query.SortBy || cursor.Order != query.Order {
+		return nil, "", ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch query.SortBy {
+	case "manufactured":
+		var manufactured time.Time
+		err = json.Unmarshal(cursor.Value, &manufactured)
+		value = manufactured
+	default:
+		var text string
+		err = json.Unmarshal(cursor.Value, &text)
+		value = text
+	}
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	return value, cursor.ID, nil
+}
+
+// compare the sort values, -1 when a is less than b, 1 when a is greater
+// than b and 0 when both are equal
+func compareSortValues(a interface{}, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		switch {
+		case a < b.(int):
+			return -1
+		case a > b.(int):
+			return 1
+		}
+	case time.Time:
+		switch {
+		case a.Before(b.(time.Time)):
+			return -1
+		case a.After(b.(time.Time)):
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestSurvivorCursor(t *testing.T) {
+	created := time.Date(2022, 4, 1, 10, 30, 0, 0, time.UTC)
+	survivor := models.Survivor{ID: "srv1", Name: "survivor1", Age: 16, CreatedAt: created}
+
+	tests := []struct {
+		sortBy string
+		value  interface{}
+	}{
+		{"name", "survivor1"},
+		{"age", 16},
+		{"created", created},
+		{"", created},
+	}
+	for _, test := range tests {
+		query := models.SurvivorQuery{SortBy: test.sortBy, Order: "desc"}
+		cursor, err := encodeSurvivorCursor(survivor, query)
+		if err != nil {
+			t.Fatalf("sort %q: unable to encode the cursor: %v", test.sortBy, err)
+		}
+		query.Cursor = cursor
+		value, id, err := decodeSurvivorCursor(query)
+		if err != nil {
+			t.Fatalf("sort %q: unable to decode the cursor: %v", test.sortBy, err)
+		}
+		if compareSortValues(value, test.value) != 0 || id != "srv1" {
+			t.Errorf("sort %q: decoded %v and %q, want %v and %q", test.sortBy, value, id, test.value, "srv1")
+		}
+	}
+}
+
+func TestSurvivorCursorRejected(t *testing.T) {
+	cursor, err := encodeSurvivorCursor(models.Survivor{ID: "srv1", Age: 16}, models.SurvivorQuery{SortBy: "age", Order: "asc"})
+	if err != nil {
+		t.Fatalf("unable to encode the cursor: %v", err)
+	}
+
+	tests := map[string]models.SurvivorQuery{
+		"not base64":       {SortBy: "age", Order: "asc", Cursor: "%%%"},
+		"not json":         {SortBy: "age", Order: "asc", Cursor: base64.RawURLEncoding.EncodeToString([]byte("age"))},
+		"other sort field": {SortBy: "name", Order: "asc", Cursor: cursor},
+		"other order":      {SortBy: "age", Order: "desc", Cursor: cursor},
+	}
+	for name, query := range tests {
+		if _, _, err := decodeSurvivorCursor(query); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: error = %v, want %v", name, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestRobotCursor(t *testing.T) {
+	manufactured := time.Date(2021, 8, 2, 13, 5, 0, 0, time.UTC)
+	robot := models.RobotList{SerialNumber: "S1", Model: "R2", ManufacturedAt: manufactured}
+
+	tests := []struct {
+		sortBy string
+		value  interface{}
+	}{
+		{"serial", "S1"},
+		{"model", "R2"},
+		{"manufactured", manufactured},
+	}
+	for _, test := range tests {
+		query := models.RobotQuery{SortBy: test.sortBy, Order: "asc"}
+		cursor, err := encodeRobotCursor(robot, query)
+		if err != nil {
+			t.Fatalf("sort %q: unable to encode the cursor: %v", test.sortBy, err)
+		}
+		query.Cursor = cursor
+		value, serialNumber, err := decodeRobotCursor(query)
+		if err != nil {
+			t.Fatalf("sort %q: unable to decode the cursor: %v", test.sortBy, err)
+		}
+		if compareSortValues(value, test.value) != 0 || serialNumber != "S1" {
+			t.Errorf("sort %q: decoded %v and %q, want %v and %q", test.sortBy, value, serialNumber, test.value, "S1")
+		}
+	}
+
+	// the cursor of another sorting is rejected
+	cursor, err := encodeRobotCursor(robot, models.RobotQuery{SortBy: "model", Order: "asc"})
+	if err != nil {
+		t.Fatalf("unable to encode the cursor: %v", err)
+	}
+	_, _, err = decodeRobotCursor(models.RobotQuery{SortBy: "manufactured", Order: "asc", Cursor: cursor})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongo infection report services
+type MongoInfectionReportServices struct {
+	Collection *mongo.Collection
+}
+
+// initiate new infection report services
+func NewMongoInfectionReportServices() *MongoInfectionReportServices {
+	return &MongoInfectionReportServices{}
+}
+
+// list the infection reports against the survivor
+func (sr *MongoInfectionReportServices) List(survivorID string) ([]models.InfectionReportRecord, error) {
+	var collected_data []models.InfectionReportRecord
+	ctx := context.TODO()
+	cursor, err := sr.Collection.Find(ctx, bson.M{
+		"survivorid": survivorID,
+	}, options.Find().SetSort(bson.M{"createdat": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.InfectionReportRecord
+		err = cursor.Decode(&result)
+		if err != nil {
+			continue
+		}
+		collected_data = append(collected_data, result)
+	}
+	return collected_data, nil
+}
@@ -2,37 +2,44 @@ package db
 
 import (
 	"context"
+	"regexp"
 	"robot-apocalypse/pkg/models"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// robots services
-type RobotsServices struct {
+// mongo robots services
+type MongoRobotsServices struct {
+	Client     *mongo.Client
 	Collection *mongo.Collection
+	SyncLog    *mongo.Collection
 }
 
 // initiate new service
-func NewRobotsServices() *RobotsServices {
-	return &RobotsServices{}
+func NewMongoRobotsServices() *MongoRobotsServices {
+	return &MongoRobotsServices{}
 }
 
-// New survivor entry
-func (sr *RobotsServices) LoadData(data []models.RobotList) error {
-	docs := make([]interface{}, 0)
-	for _, doc := range data {
-		docs = append(docs, doc)
+// replace the stored robot list
+// the robots are upserted by the serial number and the robots missing from
+// the list are removed in one transaction, so the readers never see a half
+// loaded or an empty list
+func (sr *MongoRobotsServices) LoadData(data []models.RobotList) error {
+	if len(data) == 0 {
+		return ErrEmptyRobotList
 	}
-	// remove existing lists and store new items
-	sr.Collection.DeleteMany(context.TODO(), bson.M{})
-
-	_, err := sr.Collection.InsertMany(context.TODO(), docs)
-	return err
+	serialNumbers := make([]string, 0, len(data))
+	for _, robot := range data {
+		serialNumbers = append(serialNumbers, robot.SerialNumber)
+	}
+	return sr.apply(data, bson.M{"serialnumber": bson.M{"$nin": serialNumbers}})
 }
 
 // list robots
-func (sr *RobotsServices) ListData() ([]models.RobotList, error) {
+func (sr *MongoRobotsServices) ListData() ([]models.RobotList, error) {
 	var collected_data []models.RobotList
 	ctx := context.TODO()
 	cursor, err := sr.Collection.Find(ctx, bson.M{})
@@ -46,9 +53,252 @@ func (sr *RobotsServices) ListData() ([]models.RobotList, error) {
 		var result models.RobotList
 		err = cursor.Decode(&result)
 		if err != nil {
-			continue
+			return nil, err
 		}
 		collected_data = append(collected_data, result)
 	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return collected_data, nil
+}
+
+// fetch the robot with the serial number
+func (sr *MongoRobotsServices) GetRobot(serialNumber string) (*models.RobotList, error) {
+	var collected_data models.RobotList
+	err := sr.Collection.FindOne(context.TODO(), bson.M{"serialnumber": serialNumber}).Decode(&collected_data)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collected_data, nil
+}
+
+// filtered, sorted and paginated robots listing
+func (sr *MongoRobotsServices) ListRobots(query models.RobotQuery) (*models.RobotPage, error) {
+	ctx := context.TODO()
+	field := robotSortFields[query.SortBy]
+	order := 1
+	if query.Order == "desc" {
+		order = -1
+	}
+
+	filters := bson.A{}
+	if query.Category != "" {
+		filters = append(filters, bson.M{"category": query.Category})
+	}
+	if query.Model != "" {
+		filters = append(filters, bson.M{"model": bson.M{"$regex": "^" + regexp.QuoteMeta(query.Model)}})
+	}
+	if query.SerialNumber != "" {
+		filters = append(filters, bson.M{"serialnumber": query.SerialNumber})
+	}
+	if !query.ManufacturedFrom.IsZero() {
+		filters = append(filters, bson.M{"manufacturedat": bson.M{"$gte": query.ManufacturedFrom}})
+	}
+	if !query.ManufacturedTo.IsZero() {
+		filters = append(filters, bson.M{"manufacturedat": bson.M{"$lte": query.ManufacturedTo}})
+	}
+
+	// continue after the last robot of the previous page
+	if query.Cursor != "" {
+		value, serialNumber, err := decodeRobotCursor(query)
+		if err != nil {
+			return nil, err
+		}
+		operator := "$gt"
+		if order < 0 {
+			operator = "$lt"
+		}
+		filters = append(filters, bson.M{"$or": bson.A{
+			bson.M{field: bson.M{operator: value}},
+			bson.M{field: value, "serialnumber": bson.M{operator: serialNumber}},
+		}})
+	}
+
+	filter := bson.M{}
+	if len(filters) > 0 {
+		filter["$and"] = filters
+	}
+
+	// fetch one more entry to find out there is a next page
+	sorting := bson.D{{Key: field, Value: order}}
+	if field != "serialnumber" {
+		sorting = append(sorting, bson.E{Key: "serialnumber", Value: order})
+	}
+	cursor, err := sr.Collection.Find(ctx, filter, options.Find().
+		SetSort(sorting).
+		SetLimit(int64(query.Limit+1)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	page := &models.RobotPage{Robots: []models.RobotList{}}
+	if err = cursor.All(ctx, &page.Robots); err != nil {
+		return nil, err
+	}
+
+	if len(page.Robots) > query.Limit {
+		page.Robots = page.Robots[:query.Limit]
+		page.NextCursor, err = encodeRobotCursor(page.Robots[query.Limit-1], query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// grouped robot count of the report pipeline
+type robotGroup struct {
+	ID    string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+// robot report
+// the counts are computed with one aggregation, each facet is a part of the
+// report
+func (sr *MongoRobotsServices) Report() (*models.RobotReport, error) {
+	ctx := context.TODO()
+	// robots loaded before the dates were parsed have no manufactured date
+	dated := bson.D{{Key: "$match", Value: bson.M{"manufacturedat": bson.M{"$gt": time.Time{}}}}}
+	countBy := func(field string) bson.A {
+		return bson.A{
+			bson.D{{Key: "$group", Value: bson.M{"_id": field, "count": bson.M{"$sum": 1}}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		}
+	}
+	edge := func(order int) bson.A {
+		return bson.A{
+			dated,
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "manufacturedat", Value: order}, {Key: "serialnumber", Value: 1}}}},
+			bson.D{{Key: "$limit", Value: 1}},
+		}
+	}
+
+	cursor, err := sr.Collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$facet", Value: bson.M{
+			"total":    bson.A{bson.D{{Key: "$count", Value: "count"}}},
+			"category": countBy("$category"),
+			"model":    countBy("$model"),
+			"year": bson.A{
+				dated,
+				bson.D{{Key: "$group", Value: bson.M{"_id": bson.M{"$year": "$manufacturedat"}, "count": bson.M{"$sum": 1}}}},
+				bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}},
+			},
+			"oldest": edge(1),
+			"newest": edge(-1),
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var collected_data []struct {
+		Total    []robotGroup `bson:"total"`
+		Category []robotGroup `bson:"category"`
+		Model    []robotGroup `bson:"model"`
+		Year     []struct {
+			ID    int `bson:"_id"`
+			Count int `bson:"count"`
+		} `bson:"year"`
+		Oldest []models.RobotList `bson:"oldest"`
+		Newest []models.RobotList `bson:"newest"`
+	}
+	if err = cursor.All(ctx, &collected_data); err != nil {
+		return nil, err
+	}
+
+	report := &models.RobotReport{
+		ByCategory: []models.RobotCount{},
+		ByModel:    []models.RobotCount{},
+		ByYear:     []models.RobotYearCount{},
+	}
+	if len(collected_data) == 0 {
+		return report, nil
+	}
+	facets := collected_data[0]
+	if len(facets.Total) > 0 {
+		report.Total = facets.Total[0].Count
+	}
+	for _, group := range facets.Category {
+		report.ByCategory = append(report.ByCategory, models.RobotCount{Value: group.ID, Count: group.Count})
+	}
+	for _, group := range facets.Model {
+		report.ByModel = append(report.ByModel, models.RobotCount{Value: group.ID, Count: group.Count})
+	}
+	for _, group := range facets.Year {
+		report.ByYear = append(report.ByYear, models.RobotYearCount{Year: group.ID, Count: group.Count})
+	}
+	if len(facets.Oldest) > 0 {
+		report.Oldest = &facets.Oldest[0]
+	}
+	if len(facets.Newest) > 0 {
+		report.Newest = &facets.Newest[0]
+	}
+	return report, nil
+}
+
+// apply the changes of a sync
+func (sr *MongoRobotsServices) Sync(upserts []models.RobotList, removals []string) error {
+	var removed bson.M
+	if len(removals) > 0 {
+		removed = bson.M{"serialnumber": bson.M{"$in": removals}}
+	}
+	return sr.apply(upserts, removed)
+}
+
+// upsert the robots by the serial number and remove the robots matching the
+// filter in one transaction, nothing is removed when the filter is nil
+func (sr *MongoRobotsServices) apply(upserts []models.RobotList, removed bson.M) error {
+	writes := make([]mongo.WriteModel, 0, len(upserts)+1)
+	for _, robot := range upserts {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"serialnumber": robot.SerialNumber}).
+			SetReplacement(robot).
+			SetUpsert(true))
+	}
+	if removed != nil {
+		writes = append(writes, mongo.NewDeleteManyModel().SetFilter(removed))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	ctx := context.TODO()
+	session, err := sr.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return sr.Collection.BulkWrite(sessCtx, writes)
+	})
+	return err
+}
+
+// New sync log entry
+func (sr *MongoRobotsServices) NewSyncLog(entry models.RobotSyncLog) error {
+	_, err := sr.SyncLog.InsertOne(context.TODO(), entry)
+	return err
+}
+
+// latest sync log entries
+func (sr *MongoRobotsServices) SyncLogs(limit int) ([]models.RobotSyncLog, error) {
+	var collected_data []models.RobotSyncLog
+	ctx := context.TODO()
+	cursor, err := sr.SyncLog.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"startedat": -1}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &collected_data); err != nil {
+		return nil, err
+	}
 	return collected_data, nil
 }
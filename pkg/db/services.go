@@ -0,0 +1,190 @@
+package db
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// adapter services
+// Every storage backend (mongo, memory) has to satisfy this interface
+type Services interface {
+	Survivors() SurvivorServices
+	Robots() RobotsServices
+	Shelter() ShelterServices
+	Appeals() AppealServices
+	Trades() TradeServices
+	Reports() InfectionReportServices
+	Idempotency() IdempotencyServices
+	APIKeys() APIKeyServices
+	Credentials() CredentialServices
+	Sightings() SightingServices
+	// create the indexes required by the queries
+	EnsureIndexes() error
+}
+
+// survivor storage services
+type SurvivorServices interface {
+	// New survivor entry, ErrSurvivorExists when the id is already taken
+	New(data models.Survivor) error
+	// fetch the survivor details, nil when the survivor not exists
+	GetSurvivor(id string) (*models.Survivor, error)
+	// check survivor exists or not with the id
+	CheckSurvivorExists(id string) (bool, error)
+	// Update survivor entry, ErrSurvivorNotFound when the survivor not
+	// exists, ErrInventoryLocked when the resources of an infected survivor
+	// are changed
+	Update(data models.Survivor) error
+	// apply quantity changes on the survivor inventory and return the
+	// updated inventory, ErrInsufficientStock when an item would go negative
+	AdjustInventory(id string, changes models.Resources) (models.Resources, error)
+	// move the locked inventory of an infected survivor to the shelter pool
+	// and return the moved items
+	RedistributeInventory(id string) (models.Resources, error)
+	// report the survivor as infected and keep the report details in the
+	// same write, each reporter is counted once, ErrAlreadyReported for the
+	// repeated reports
+	Infected(report models.InfectionReportRecord) error
+	// remove the report of the reporter with its details and recompute the
+	// reported count, ErrReportNotFound when the reporter has no report
+	// against the survivor
+	RetractReport(id string, reporter string) error
+	// insert new change location history
+	NewLocationHistory(id string, location models.Location) error
+	// location history of the survivor ordered by the time, zero from or to
+	// leaves the respective side of the time range open
+	GetLocationHistory(id string, from time.Time, to time.Time) ([]models.LocationHistory, error)
+	// count of infected survivors
+	InfectedCount() (int, error)
+	// Count total survivors
+	TotalSurvivors() (int, error)
+	// list infected or non-infected survivors
+	GetSurvivors(criteria string) ([]models.Survivor, error)
+	// survivors within the radius of the point sorted by the distance, the
+	// query should be already validated and defaulted
+	Nearby(query models.NearbyQuery) ([]models.NearbySurvivor, error)
+	// filtered, sorted and paginated survivors listing, the query should be
+	// already validated and defaulted
+	ListSurvivors(query models.SurvivorQuery) (*models.SurvivorPage, error)
+}
+
+// robots storage services
+type RobotsServices interface {
+	// replace the stored robot list, the robots are upserted by the serial
+	// number, ErrEmptyRobotList when the list is empty
+	LoadData(data []models.RobotList) error
+	// list robots
+	ListData() ([]models.RobotList, error)
+	// fetch the robot with the serial number, nil when the robot not exists
+	GetRobot(serialNumber string) (*models.RobotList, error)
+	// filtered, sorted and paginated robots listing, the query should be
+	// already validated and defaulted
+	ListRobots(query models.RobotQuery) (*models.RobotPage, error)
+	// robot counts by category, model and manufacture year with the oldest
+	// and the newest robots, the robots without a manufactured date are
+	// left out of the years and the oldest/newest
+	Report() (*models.RobotReport, error)
+	// apply the changes of a sync, the upserts are matched by the serial
+	// number
+	Sync(upserts []models.RobotList, removals []string) error
+	// New sync log entry
+	NewSyncLog(entry models.RobotSyncLog) error
+	// latest sync log entries, newest first
+	SyncLogs(limit int) ([]models.RobotSyncLog, error)
+}
+
+// robot sighting storage services
+type SightingServices interface {
+	// New robot sighting entry
+	New(sighting models.RobotSighting) error
+	// sightings within the area and the time window, the latest first, the
+	// query should be already validated and defaulted
+	List(query models.SightingQuery) ([]models.RobotSighting, error)
+	// sightings grouped into the grid cells of the query, the cells with the
+	// most robots first, the query should be already validated and defaulted
+	ThreatMap(query models.ThreatMapQuery) ([]models.ThreatCell, error)
+}
+
+// shelter pool storage services
+type ShelterServices interface {
+	// shelter pool inventory
+	Inventory() (models.Resources, error)
+}
+
+// infection appeal storage services
+type AppealServices interface {
+	// New appeal entry, ErrAppealPending when the survivor already has a
+	// pending appeal
+	New(appeal models.Appeal) error
+	// fetch the appeal, nil when the appeal not exists
+	Get(id string) (*models.Appeal, error)
+	// list appeals, all of them when the status is empty
+	List(status models.AppealStatus) ([]models.Appeal, error)
+	// move a pending appeal to the given status, ErrAppealResolved when the
+	// appeal is not pending anymore, the infection reports of the survivor
+	// are cleared with an upheld appeal in the same write
+	Resolve(id string, status models.AppealStatus) (*models.Appeal, error)
+}
+
+// trade proposal storage services
+type TradeServices interface {
+	// New trade proposal entry
+	New(proposal models.TradeProposal) error
+	// fetch the trade proposal, nil when the proposal not exists
+	Get(id string) (*models.TradeProposal, error)
+	// trade proposals from or to the survivor, the latest first, all of
+	// them when the status is empty
+	List(survivorID string, status models.TradeStatus) ([]models.TradeProposal, error)
+	// move a pending proposal to the given status, ErrTradeResolved when
+	// the proposal is not pending anymore, the offered items are swapped
+	// with an accepted proposal in the same write, either both inventories
+	// are changed or none of them
+	Resolve(id string, status models.TradeStatus) (*models.TradeProposal, error)
+}
+
+// infection report storage services
+type InfectionReportServices interface {
+	// list the infection reports against the survivor, the reports are
+	// written with the survivor reported count by the survivor services
+	List(survivorID string) ([]models.InfectionReportRecord, error)
+}
+
+// idempotency key storage services
+type IdempotencyServices interface {
+	// reserve the key for a request, returns the existing record when the
+	// key is already used and nil when the key is reserved
+	Reserve(key string, fingerprint string) (*models.IdempotencyRecord, error)
+	// keep the response of the request
+	Complete(key string, statusCode int, body []byte) error
+	// remove the reservation, so the request can be retried
+	Release(key string) error
+}
+
+// api key storage services
+type APIKeyServices interface {
+	// New api key entry, ErrAPIKeyExists when the id or the hash is already
+	// stored
+	New(key models.APIKey) error
+	// fetch the active (not revoked) key with the hash, nil when there is no
+	// such key
+	GetByHash(hash string) (*models.APIKey, error)
+	// list api keys, including the revoked ones
+	List() ([]models.APIKey, error)
+	// revoke the key, ErrAPIKeyNotFound when the key not exists
+	Revoke(id string) (*models.APIKey, error)
+	// change the role of the key, ErrAPIKeyNotFound when the key not exists
+	SetRole(id string, role models.Role) error
+}
+
+// survivor credential storage services
+type CredentialServices interface {
+	// set the survivor credentials, the existing credentials are replaced
+	Set(credentials models.SurvivorCredentials) error
+	// fetch the survivor credentials, nil when the survivor has no
+	// credentials
+	Get(survivorID string) (*models.SurvivorCredentials, error)
+	// New refresh token entry
+	NewRefreshToken(token models.RefreshToken) error
+	// revoke the active (not revoked, not expired) refresh token with the
+	// hash and return it, nil when there is no such token
+	ConsumeRefreshToken(hash string) (*models.RefreshToken, error)
+}
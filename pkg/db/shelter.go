@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// id of the shelter pool document
+const shelterPoolID = "shelter"
+
+// mongo shelter services
+type MongoShelterServices struct {
+	Collection *mongo.Collection
+}
+
+// initiate new shelter services
+func NewMongoShelterServices() *MongoShelterServices {
+	return &MongoShelterServices{}
+}
+
+// shelter pool inventory
+func (sr *MongoShelterServices) Inventory() (models.Resources, error) {
+	var collected_data struct {
+		Resources models.Resources
+	}
+	err := sr.Collection.FindOne(context.TODO(), bson.M{
+		"id": shelterPoolID,
+	}).Decode(&collected_data)
+
+	if err == mongo.ErrNoDocuments {
+		return models.Resources{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return collected_data.Resources, nil
+}
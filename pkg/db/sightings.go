@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"math"
+	"robot-apocalypse/pkg/models"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongo robot sighting services
+type MongoSightingServices struct {
+	Collection *mongo.Collection
+}
+
+// initiate new robot sighting services
+func NewMongoSightingServices() *MongoSightingServices {
+	return &MongoSightingServices{}
+}
+
+// New robot sighting entry
+func (sr *MongoSightingServices) New(data models.RobotSighting) error {
+	_, err := sr.Collection.InsertOne(context.TODO(), data)
+	return err
+}
+
+// sightings within the area and the time window
+// $centerSphere keeps the time order, $geoNear would sort by the distance
+func (sr *MongoSightingServices) List(query models.SightingQuery) ([]models.RobotSighting, error) {
+	ctx := context.TODO()
+	filter := bson.M{}
+	if query.Latitude != nil && query.Longitude != nil {
+		filter["location"] = bson.M{"$geoWithin": bson.M{
+			"$centerSphere": bson.A{
+				bson.A{*query.Longitude, *query.Latitude},
+				query.Radius / earthRadius,
+			},
+		}}
+	}
+	seenAt := bson.M{}
+	if !query.SeenFrom.IsZero() {
+		seenAt["$gte"] = query.SeenFrom
+	}
+	if !query.SeenTo.IsZero() {
+		seenAt["$lte"] = query.SeenTo
+	}
+	if len(seenAt) > 0 {
+		filter["seenat"] = seenAt
+	}
+	if query.SerialNumber != "" {
+		filter["serialnumber"] = query.SerialNumber
+	}
+	if query.Category != "" {
+		filter["category"] = query.Category
+	}
+
+	cursor, err := sr.Collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "seenat", Value: -1}, {Key: "id", Value: -1}}).
+		SetLimit(int64(query.Limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	collected_data := []models.RobotSighting{}
+	if err = cursor.All(ctx, &collected_data); err != nil {
+		return nil, err
+	}
+	return collected_data, nil
+}
+
+// sightings grouped into the grid cells
+// the row and the column of the cell are computed from the coordinates of
+// the sighting, [longitude, latitude]
+func (sr *MongoSightingServices) ThreatMap(query models.ThreatMapQuery) ([]models.ThreatCell, error) {
+	ctx := context.TODO()
+	filter := bson.M{"seenat": bson.M{"$gte": query.SeenFrom, "$lte": query.SeenTo}}
+	if query.Category != "" {
+		filter["category"] = query.Category
+	}
+	if query.MinLatitude != nil {
+		filter["location.coordinates.1"] = bson.M{"$gte": *query.MinLatitude, "$lte": *query.MaxLatitude}
+		filter["location.coordinates.0"] = bson.M{"$gte": *query.MinLongitude, "$lte": *query.MaxLongitude}
+	}
+	cell := func(index int) bson.M {
+		return bson.M{"$floor": bson.M{"$divide": bson.A{
+			bson.M{"$arrayElemAt": bson.A{"$location.coordinates", index}},
+			query.CellSize,
+		}}}
+	}
+
+	cursor, err := sr.Collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       bson.M{"row": cell(1), "column": cell(0)},
+			"sightings": bson.M{"$sum": 1},
+			"robots":    bson.M{"$sum": "$count"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID struct {
+			Row    float64 `bson:"row"`
+			Column float64 `bson:"column"`
+		} `bson:"_id"`
+		Sightings int `bson:"sightings"`
+		Robots    int `bson:"robots"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	collected_data := make([]models.ThreatCell, 0, len(groups))
+	for _, group := range groups {
+		threatCell := newThreatCell(int(group.ID.Row), int(group.ID.Column), query.CellSize)
+		threatCell.Sightings = group.Sightings
+		threatCell.Robots = group.Robots
+		collected_data = append(collected_data, threatCell)
+	}
+	sortThreatCells(collected_data)
+	return collected_data, nil
+}
+
+// grid position of the location
+func threatCellOf(location models.Location, cellSize float64) (int, int) {
+	return int(math.Floor(float64(location.Latitude) / cellSize)), int(math.Floor(float64(location.Longitude) / cellSize))
+}
+
+// grid cell with the bounds, the bounds are rounded to drop the floating
+// point noise of the multiplication
+func newThreatCell(row int, column int, cellSize float64) models.ThreatCell {
+	bound := func(index int) float64 {
+		return math.Round(float64(index)*cellSize*1e9) / 1e9
+	}
+	return models.ThreatCell{
+		Row:          row,
+		Column:       column,
+		MinLatitude:  bound(row),
+		MinLongitude: bound(column),
+		MaxLatitude:  bound(row + 1),
+		MaxLongitude: bound(column + 1),
+	}
+}
+
+// the cells with the most robots first, the position breaks the ties
+func sortThreatCells(cells []models.ThreatCell) {
+	sort.Slice(cells, func(i, j int) bool {
+		switch {
+		case cells[i].Robots != cells[j].Robots:
+			return cells[i].Robots > cells[j].Robots
+		case cells[i].Row != cells[j].Row:
+			return cells[i].Row < cells[j].Row
+		default:
+			return cells[i].Column < cells[j].Column
+		}
+	})
+}
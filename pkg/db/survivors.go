@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"robot-apocalypse/pkg/models"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -11,25 +12,39 @@ import (
 
 var InfectionMinimumReportCount = 3
 
-// survivor services
-type SurvivorServices struct {
+// mongo survivor services
+type MongoSurvivorServices struct {
+	Client          *mongo.Client
 	Collection      *mongo.Collection
 	LocationHistory *mongo.Collection
+	Shelter         *mongo.Collection
+	Reports         *mongo.Collection
 }
 
 // initiate new survivor services
-func NewSurvivorServices() *SurvivorServices {
-	return &SurvivorServices{}
+func NewMongoSurvivorServices() *MongoSurvivorServices {
+	return &MongoSurvivorServices{}
 }
 
 // New survivor entry
-func (sr *SurvivorServices) New(data models.Survivor) error {
+func (sr *MongoSurvivorServices) New(data models.Survivor) error {
 	_, err := sr.Collection.InsertOne(context.TODO(), data)
-	return err
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSurvivorExists
+	}
+	if err != nil {
+		return err
+	}
+
+	// starting point of the escape track
+	if (data.Location != models.Location{}) {
+		sr.NewLocationHistory(data.ID, data.Location)
+	}
+	return nil
 }
 
 // fetch the survivor details
-func (sr *SurvivorServices) GetSurvivor(id string) (*models.Survivor, error) {
+func (sr *MongoSurvivorServices) GetSurvivor(id string) (*models.Survivor, error) {
 	var collected_data *models.Survivor
 	queryOptions := options.FindOneOptions{}
 
@@ -45,7 +60,7 @@ func (sr *SurvivorServices) GetSurvivor(id string) (*models.Survivor, error) {
 }
 
 // check survivor exists or not with the id
-func (sr *SurvivorServices) CheckSurvivorExists(id string) (bool, error) {
+func (sr *MongoSurvivorServices) CheckSurvivorExists(id string) (bool, error) {
 	exists, err := sr.Collection.CountDocuments(context.TODO(), bson.M{
 		"id": id,
 	})
@@ -63,7 +78,7 @@ func (sr *SurvivorServices) CheckSurvivorExists(id string) (bool, error) {
 }
 
 // Update survivor entry
-func (sr *SurvivorServices) Update(data models.Survivor) error {
+func (sr *MongoSurvivorServices) Update(data models.Survivor) error {
 	var currentLocation models.Location
 
 	// based on the input it will update the entries
@@ -79,16 +94,35 @@ func (sr *SurvivorServices) Update(data models.Survivor) error {
 		updateList["location"] = data.Location
 		currentLocation = data.Location
 	}
+	filter := bson.M{
+		"id": data.ID,
+	}
+	if data.Resources != nil {
+		updateList["resources"] = data.Resources
+		// inventory of the infected survivors are locked
+		filter["reportedcount"] = bson.M{"$not": bson.M{"$gte": InfectionMinimumReportCount}}
+	}
 
 	// update
-	_, err := sr.Collection.UpdateOne(
+	result, err := sr.Collection.UpdateOne(
 		context.TODO(),
-		bson.M{
-			"id": data.ID,
-		},
+		filter,
 		bson.M{
 			"$set": updateList,
 		})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		exists, err := sr.CheckSurvivorExists(data.ID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrSurvivorNotFound
+		}
+		return ErrInventoryLocked
+	}
 
 	// when the request includes location change, then we will keep an history.
 	// with that history we can locate the escape track of that survivor
@@ -99,33 +133,279 @@ func (sr *SurvivorServices) Update(data models.Survivor) error {
 	return err
 }
 
-// Update survivor entry
-func (sr *SurvivorServices) Infected(id string, infect_reported string) error {
-	_, err := sr.Collection.UpdateOne(context.TODO(), bson.M{
-		"id": id,
-	}, bson.M{
-		"$inc": bson.M{
-			"reportedcount": 1,
-		},
-		"$push": bson.M{
-			"reportedby": infect_reported,
-		},
+// change the inventory quantities
+// the update filter only matches when every removed item has enough stock, so
+// the check and the change happens in a single atomic operation
+func (sr *MongoSurvivorServices) AdjustInventory(id string, changes models.Resources) (models.Resources, error) {
+	filter := bson.M{
+		"id":            id,
+		"reportedcount": bson.M{"$not": bson.M{"$gte": InfectionMinimumReportCount}},
+	}
+	increments := bson.M{}
+	for item, quantity := range changes {
+		if quantity < 0 {
+			filter["resources."+string(item)] = bson.M{"$gte": -quantity}
+		}
+		increments["resources."+string(item)] = quantity
+	}
+
+	var collected_data models.Survivor
+	err := sr.Collection.FindOneAndUpdate(context.TODO(), filter, bson.M{
+		"$inc": increments,
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&collected_data)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, sr.inventoryMismatch(context.TODO(), id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return collected_data.Resources, nil
+}
+
+// remove the given items and add the received items to the inventory
+// the filter only matches a non infected survivor with enough stock
+func (sr *MongoSurvivorServices) exchangeItems(ctx context.Context, id string, given models.Resources, received models.Resources) error {
+	filter := bson.M{
+		"id":            id,
+		"reportedcount": bson.M{"$not": bson.M{"$gte": InfectionMinimumReportCount}},
+	}
+	increments := bson.M{}
+	for item, quantity := range given {
+		filter["resources."+string(item)] = bson.M{"$gte": quantity}
+		increments["resources."+string(item)] = -quantity
+	}
+	for item, quantity := range received {
+		key := "resources." + string(item)
+		current, _ := increments[key].(int)
+		increments[key] = current + quantity
+	}
+
+	result, err := sr.Collection.UpdateOne(ctx, filter, bson.M{
+		"$inc": increments,
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+	return sr.inventoryMismatch(ctx, id)
+}
+
+// find out why an inventory update filter didn't match the survivor
+func (sr *MongoSurvivorServices) inventoryMismatch(ctx context.Context, id string) error {
+	var collected_data models.Survivor
+	err := sr.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&collected_data)
+	if err == mongo.ErrNoDocuments {
+		return ErrSurvivorNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if collected_data.ReportedCount >= InfectionMinimumReportCount {
+		return ErrInventoryLocked
+	}
+	return ErrInsufficientStock
+}
+
+// move the locked inventory of an infected survivor to the shelter pool
+// the survivor inventory is emptied and added to the shelter inside a
+// transaction, so the items are never lost or duplicated
+func (sr *MongoSurvivorServices) RedistributeInventory(id string) (models.Resources, error) {
+	ctx := context.TODO()
+	session, err := sr.Client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	moved, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var collected_data models.Survivor
+		err := sr.Collection.FindOneAndUpdate(sessCtx, bson.M{
+			"id":            id,
+			"reportedcount": bson.M{"$gte": InfectionMinimumReportCount},
+		}, bson.M{
+			"$set": bson.M{"resources": models.Resources{}},
+		}).Decode(&collected_data)
+		if err == mongo.ErrNoDocuments {
+			exists, err := sr.CheckSurvivorExists(id)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, ErrSurvivorNotFound
+			}
+			return nil, ErrInventoryNotLocked
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		increments := bson.M{}
+		for item, quantity := range collected_data.Resources {
+			if quantity > 0 {
+				increments["resources."+string(item)] = quantity
+			}
+		}
+		if len(increments) > 0 {
+			_, err = sr.Shelter.UpdateOne(sessCtx, bson.M{
+				"id": shelterPoolID,
+			}, bson.M{
+				"$inc": increments,
+			}, options.Update().SetUpsert(true))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return collected_data.Resources, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved.(models.Resources), nil
+}
+
+// report the survivor as infected
+// the filter only matches when the reporter is not in the reportedby list, so
+// concurrent duplicate reports can't increment the count twice, the report
+// details are inserted in the same transaction
+func (sr *MongoSurvivorServices) Infected(report models.InfectionReportRecord) error {
+	ctx := context.TODO()
+	session, err := sr.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := sr.Collection.UpdateOne(sessCtx, bson.M{
+			"id":         report.SurvivorID,
+			"reportedby": bson.M{"$ne": report.ReportedBy},
+		}, bson.M{
+			"$inc": bson.M{
+				"reportedcount": 1,
+			},
+			"$push": bson.M{
+				"reportedby": report.ReportedBy,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, sr.reportMismatch(sessCtx, report.SurvivorID, ErrAlreadyReported)
+		}
+		_, err = sr.Reports.InsertOne(sessCtx, report)
+		return nil, err
 	})
 	return err
 }
 
+// retract an infection report
+// the reporter is removed from the reportedby list and the reported count is
+// recomputed from the remaining reports in the same update, the report
+// details are removed in the same transaction
+func (sr *MongoSurvivorServices) RetractReport(id string, reporter string) error {
+	ctx := context.TODO()
+	session, err := sr.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		result, err := sr.Collection.UpdateOne(sessCtx, bson.M{
+			"id":         id,
+			"reportedby": reporter,
+		}, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"reportedby": bson.M{"$filter": bson.M{
+					"input": "$reportedby",
+					"cond":  bson.M{"$ne": bson.A{"$$this", bson.M{"$literal": reporter}}},
+				}},
+			}}},
+			{{Key: "$set", Value: bson.M{
+				"reportedcount": bson.M{"$size": "$reportedby"},
+			}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, sr.reportMismatch(sessCtx, id, ErrReportNotFound)
+		}
+		_, err = sr.Reports.DeleteMany(sessCtx, bson.M{
+			"survivorid": id,
+			"reportedby": reporter,
+		})
+		return nil, err
+	})
+	return err
+}
+
+// find out why a report update filter didn't match the survivor
+func (sr *MongoSurvivorServices) reportMismatch(ctx context.Context, id string, reason error) error {
+	count, err := sr.Collection.CountDocuments(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSurvivorNotFound
+	}
+	return reason
+}
+
 // insert new change location history
-func (sr *SurvivorServices) NewLocationHistory(id string, location models.Location) error {
-	_, err := sr.LocationHistory.InsertOne(context.TODO(), bson.M{
-		"id":       id,
-		"location": location,
+func (sr *MongoSurvivorServices) NewLocationHistory(id string, location models.Location) error {
+	_, err := sr.LocationHistory.InsertOne(context.TODO(), models.LocationHistory{
+		ID:        id,
+		Location:  location,
+		Timestamp: time.Now().UTC(),
 	})
 
 	return err
 }
 
+// location history of the survivor
+func (sr *MongoSurvivorServices) GetLocationHistory(id string, from time.Time, to time.Time) ([]models.LocationHistory, error) {
+	var collected_data []models.LocationHistory
+	ctx := context.TODO()
+	filter := bson.M{
+		"id": id,
+	}
+	timeRange := bson.M{}
+	if !from.IsZero() {
+		timeRange["$gte"] = from
+	}
+	if !to.IsZero() {
+		timeRange["$lte"] = to
+	}
+	if len(timeRange) > 0 {
+		filter["timestamp"] = timeRange
+	}
+
+	cursor, err := sr.LocationHistory.Find(ctx, filter, options.Find().SetSort(bson.M{"timestamp": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.LocationHistory
+		err = cursor.Decode(&result)
+		if err != nil {
+			continue
+		}
+		collected_data = append(collected_data, result)
+	}
+	return collected_data, nil
+}
+
 // prepare infection report
-func (sr *SurvivorServices) InfectedCount() (int, error) {
+func (sr *MongoSurvivorServices) InfectedCount() (int, error) {
 	count, err := sr.Collection.CountDocuments(context.TODO(), bson.M{
 		"reportedcount": bson.M{"$gte": InfectionMinimumReportCount},
 	})
@@ -138,7 +418,7 @@ func (sr *SurvivorServices) InfectedCount() (int, error) {
 }
 
 // Count total survivors
-func (sr *SurvivorServices) TotalSurvivors() (int, error) {
+func (sr *MongoSurvivorServices) TotalSurvivors() (int, error) {
 	count, err := sr.Collection.CountDocuments(context.TODO(), bson.M{})
 
 	if err != nil && err != mongo.ErrNoDocuments {
@@ -149,7 +429,7 @@ func (sr *SurvivorServices) TotalSurvivors() (int, error) {
 }
 
 // list infected survivors
-func (sr *SurvivorServices) GetSurvivors(criteria string) ([]models.Survivor, error) {
+func (sr *MongoSurvivorServices) GetSurvivors(criteria string) ([]models.Survivor, error) {
 	var collected_data []models.Survivor
 	ctx := context.TODO()
 	var filter bson.M
@@ -180,3 +460,124 @@ func (sr *SurvivorServices) GetSurvivors(criteria string) ([]models.Survivor, er
 	}
 	return collected_data, nil
 }
+
+// filtered, sorted and paginated survivors listing
+func (sr *MongoSurvivorServices) ListSurvivors(query models.SurvivorQuery) (*models.SurvivorPage, error) {
+	ctx := context.TODO()
+	field := survivorSortFields[query.SortBy]
+	order := 1
+	if query.Order == "desc" {
+		order = -1
+	}
+
+	filters := bson.A{}
+	switch query.Status {
+	case "infected":
+		filters = append(filters, bson.M{"reportedcount": bson.M{"$gte": InfectionMinimumReportCount}})
+	case "non-infected":
+		filters = append(filters, bson.M{"reportedcount": bson.M{"$not": bson.M{"$gte": InfectionMinimumReportCount}}})
+	}
+	if query.MinAge > 0 {
+		filters = append(filters, bson.M{"age": bson.M{"$gte": query.MinAge}})
+	}
+	if query.MaxAge > 0 {
+		filters = append(filters, bson.M{"age": bson.M{"$lte": query.MaxAge}})
+	}
+	if query.Resource != "" {
+		filters = append(filters, bson.M{"resources." + string(query.Resource): bson.M{"$gt": 0}})
+	}
+
+	// continue after the last survivor of the previous page
+	if query.Cursor != "" {
+		value, id, err := decodeSurvivorCursor(query)
+		if err != nil {
+			return nil, err
+		}
+		operator := "$gt"
+		if order < 0 {
+			operator = "$lt"
+		}
+		filters = append(filters, bson.M{"$or": bson.A{
+			bson.M{field: bson.M{operator: value}},
+			bson.M{field: value, "id": bson.M{operator: id}},
+		}})
+	}
+
+	filter := bson.M{}
+	if len(filters) > 0 {
+		filter["$and"] = filters
+	}
+
+	// fetch one more entry to find out there is a next page
+	cursor, err := sr.Collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: field, Value: order}, {Key: "id", Value: order}}).
+		SetLimit(int64(query.Limit+1)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	page := &models.SurvivorPage{Survivors: []models.Survivor{}}
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.Survivor
+		err = cursor.Decode(&result)
+		if err != nil {
+			return nil, err
+		}
+		page.Survivors = append(page.Survivors, result)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Survivors) > query.Limit {
+		page.Survivors = page.Survivors[:query.Limit]
+		page.NextCursor, err = encodeSurvivorCursor(page.Survivors[query.Limit-1], query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// survivors near the point
+// $geoNear returns the survivors sorted by the distance
+func (sr *MongoSurvivorServices) Nearby(query models.NearbyQuery) ([]models.NearbySurvivor, error) {
+	ctx := context.TODO()
+	// the survivors without a location are left out
+	filter := bson.M{"location": bson.M{"$exists": true}}
+	switch query.Status {
+	case "infected":
+		filter["reportedcount"] = bson.M{"$gte": InfectionMinimumReportCount}
+	case "non-infected":
+		filter["reportedcount"] = bson.M{"$not": bson.M{"$gte": InfectionMinimumReportCount}}
+	}
+
+	cursor, err := sr.Collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":          models.Location{Latitude: float32(*query.Latitude), Longitude: float32(*query.Longitude)},
+			"distanceField": "distance",
+			"maxDistance":   query.Radius,
+			"spherical":     true,
+			"query":         filter,
+		}}},
+		{{Key: "$limit", Value: query.Limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	collected_data := []models.NearbySurvivor{}
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.NearbySurvivor
+		err = cursor.Decode(&result)
+		if err != nil {
+			continue
+		}
+		collected_data = append(collected_data, result)
+	}
+	return collected_data, nil
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"robot-apocalypse/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongo trade services
+type MongoTradeServices struct {
+	Client     *mongo.Client
+	Collection *mongo.Collection
+	Survivors  *mongo.Collection
+}
+
+// initiate new trade services
+func NewMongoTradeServices() *MongoTradeServices {
+	return &MongoTradeServices{}
+}
+
+// New trade proposal entry
+func (sr *MongoTradeServices) New(data models.TradeProposal) error {
+	_, err := sr.Collection.InsertOne(context.TODO(), data)
+	return err
+}
+
+// fetch the trade proposal details
+func (sr *MongoTradeServices) Get(id string) (*models.TradeProposal, error) {
+	var collected_data *models.TradeProposal
+	err := sr.Collection.FindOne(context.TODO(), bson.M{
+		"id": id,
+	}).Decode(&collected_data)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return collected_data, nil
+}
+
+// list trade proposals of the survivor
+func (sr *MongoTradeServices) List(survivorID string, status models.TradeStatus) ([]models.TradeProposal, error) {
+	collected_data := []models.TradeProposal{}
+	ctx := context.TODO()
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"from.id": survivorID},
+			bson.M{"to.id": survivorID},
+		},
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := sr.Collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdat": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		// Declare a result BSON object
+		var result models.TradeProposal
+		err = cursor.Decode(&result)
+		if err != nil {
+			continue
+		}
+		collected_data = append(collected_data, result)
+	}
+	return collected_data, nil
+}
+
+// resolve a pending trade proposal
+// the proposal and both inventory changes are done inside a transaction,
+// when one of the survivors can't complete the trade the whole trade is
+// rolled back and the proposal stays pending
+func (sr *MongoTradeServices) Resolve(id string, status models.TradeStatus) (*models.TradeProposal, error) {
+	ctx := context.TODO()
+	session, err := sr.Client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	resolved, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var collected_data models.TradeProposal
+		err := sr.Collection.FindOneAndUpdate(sessCtx, bson.M{
+			"id":     id,
+			"status": models.TradePending,
+		}, bson.M{
+			"$set": bson.M{
+				"status":     status,
+				"resolvedat": time.Now().UTC(),
+			},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&collected_data)
+
+		if err == mongo.ErrNoDocuments {
+			count, err := sr.Collection.CountDocuments(sessCtx, bson.M{"id": id})
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, ErrTradeNotFound
+			}
+			return nil, ErrTradeResolved
+		}
+		if err != nil {
+			return nil, err
+		}
+		if status != models.TradeAccepted {
+			return &collected_data, nil
+		}
+
+		survivors := &MongoSurvivorServices{Collection: sr.Survivors}
+		from, to := collected_data.From, collected_data.To
+		if err := survivors.exchangeItems(sessCtx, from.ID, from.Items, to.Items); err != nil {
+			return nil, err
+		}
+		if err := survivors.exchangeItems(sessCtx, to.ID, to.Items, from.Items); err != nil {
+			return nil, err
+		}
+		return &collected_data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(*models.TradeProposal), nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// GeoJSON object types
+const (
+	GeoJSONPoint             = "Point"
+	GeoJSONLineString        = "LineString"
+	GeoJSONPolygon           = "Polygon"
+	GeoJSONFeature           = "Feature"
+	GeoJSONFeatureCollection = "FeatureCollection"
+)
+
+// model GeoJSON geometry
+// coordinates are in [longitude, latitude] order
+type GeoJSONGeometry struct {
+	// type
+	Type string `json:"type"`
+	// coordinates
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// model GeoJSON feature
+type GeoJSONFeatureObject struct {
+	// type
+	Type string `json:"type"`
+	// geometry
+	Geometry GeoJSONGeometry `json:"geometry"`
+	// properties
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// model GeoJSON feature collection
+type GeoJSONFeatureCollectionObject struct {
+	// type
+	Type string `json:"type"`
+	// features
+	Features []GeoJSONFeatureObject `json:"features"`
+}
+
+// GeoJSON point as stored in the database
+type geoJSONPoint struct {
+	Type        string    `bson:"type"`
+	Coordinates []float64 `bson:"coordinates"`
+}
+
+// store the location as a GeoJSON point, so the locations can be indexed
+// with a 2dsphere index
+func (loc Location) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(geoJSONPoint{
+		Type:        GeoJSONPoint,
+		Coordinates: []float64{float64(loc.Longitude), float64(loc.Latitude)},
+	})
+}
+
+// zero location is a missing location, the omitempty fields leave it out
+func (loc Location) IsZero() bool {
+	return loc == Location{}
+}
+
+// read the location from a GeoJSON point, the legacy latitude/longitude
+// documents are still accepted
+func (loc *Location) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.Null {
+		return nil
+	}
+	var stored struct {
+		Type        string    `bson:"type"`
+		Coordinates []float64 `bson:"coordinates"`
+		Latitude    float32   `bson:"latitude"`
+		Longitude   float32   `bson:"longitude"`
+	}
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(&stored); err != nil {
+		return err
+	}
+
+	if stored.Type == GeoJSONPoint && len(stored.Coordinates) == 2 {
+		loc.Longitude = float32(stored.Coordinates[0])
+		loc.Latitude = float32(stored.Coordinates[1])
+		return nil
+	}
+	loc.Latitude = stored.Latitude
+	loc.Longitude = stored.Longitude
+	return nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSurvivorLegacyDocument(t *testing.T) {
+	// survivor as stored by the first versions
+	data, err := bson.Marshal(bson.M{
+		"id":        "srv1",
+		"location":  bson.M{"latitude": 10.5, "longitude": -20.25},
+		"resources": bson.A{"water", "food", "water"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var survivor Survivor
+	if err := bson.Unmarshal(data, &survivor); err != nil {
+		t.Fatalf("unable to decode the legacy survivor: %v", err)
+	}
+	if survivor.Location != (Location{Latitude: 10.5, Longitude: -20.25}) {
+		t.Errorf("location = %+v, want 10.5, -20.25", survivor.Location)
+	}
+	if len(survivor.Resources) != 2 || survivor.Resources[Water] != 2 || survivor.Resources[Food] != 1 {
+		t.Errorf("resources = %v, want 2 water and 1 food", survivor.Resources)
+	}
+}
+
+func TestSurvivorDocument(t *testing.T) {
+	stored := Survivor{
+		ID:        "srv1",
+		Location:  Location{Latitude: 10.5, Longitude: -20.25},
+		Resources: Resources{Water: 3},
+	}
+	data, err := bson.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the location is stored as a GeoJSON point
+	var raw struct {
+		Location struct {
+			Type        string    `bson:"type"`
+			Coordinates []float64 `bson:"coordinates"`
+		} `bson:"location"`
+	}
+	if err := bson.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Location.Type != GeoJSONPoint || len(raw.Location.Coordinates) != 2 ||
+		raw.Location.Coordinates[0] != -20.25 || raw.Location.Coordinates[1] != 10.5 {
+		t.Errorf("stored location = %+v, want a point at [-20.25, 10.5]", raw.Location)
+	}
+
+	var survivor Survivor
+	if err := bson.Unmarshal(data, &survivor); err != nil {
+		t.Fatalf("unable to decode the survivor: %v", err)
+	}
+	if survivor.Location != stored.Location || survivor.Resources[Water] != 3 {
+		t.Errorf("decoded %+v, want %+v", survivor, stored)
+	}
+}
+
+func TestSurvivorDocumentWithoutLocation(t *testing.T) {
+	data, err := bson.Marshal(Survivor{ID: "srv1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bson.Raw(data).LookupErr("location"); err == nil {
+		t.Errorf("document %v has a location, want it left out", bson.Raw(data))
+	}
+
+	var survivor Survivor
+	if err := bson.Unmarshal(data, &survivor); err != nil {
+		t.Fatalf("unable to decode the survivor: %v", err)
+	}
+	if !survivor.Location.IsZero() {
+		t.Errorf("location = %+v, want the zero location", survivor.Location)
+	}
+}
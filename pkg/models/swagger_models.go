@@ -7,7 +7,7 @@ type _ struct {
 	Body Survivor
 }
 
-// swagger:parameters idOfSurvivorInfectedEndpoint
+// swagger:parameters idOfSurvivorInfectedEndpoint idOfSurvivorInfectionRetractEndpoint
 type _ struct {
 	// in:body
 	// required:true
@@ -28,3 +28,265 @@ type _ struct {
 	// required:true
 	Criteria string `json:"criteria"`
 }
+
+// swagger:parameters idOfSurvivorInventoryEndpoint idOfSurvivorInventoryAdjustEndpoint idOfRedistributeInventoryEndpoint idOfAppealCreateEndpoint idOfSurvivorReportsEndpoint idOfSurvivorFetchEndpoint idOfSurvivorAlertsEndpoint
+type _ struct {
+	// in:path
+	// survivor id
+	// required:true
+	ID string `json:"id"`
+}
+
+// swagger:parameters idOfSurvivorInventoryAdjustEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body InventoryAdjustment
+}
+
+// swagger:parameters idOfTradeEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body Trade
+}
+
+// swagger:parameters idOfTradeResolveEndpoint
+type _ struct {
+	// in:path
+	// trade id
+	// required:true
+	ID string `json:"id"`
+	// in:body
+	// required:true
+	Body TradeDecision
+}
+
+// swagger:parameters idOfSurvivorTradesEndpoint
+type _ struct {
+	// in:path
+	// survivor id
+	// required:true
+	ID string `json:"id"`
+	// in:query
+	// pending, accepted or declined
+	Status string `json:"status"`
+}
+
+// swagger:parameters idOfAppealCreateEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body struct {
+		// reason
+		Reason string `json:"reason"`
+	}
+}
+
+// swagger:parameters idOfAppealListEndpoint
+type _ struct {
+	// in:query
+	// pending, upheld or dismissed
+	Status string `json:"status"`
+}
+
+// swagger:parameters idOfAppealResolveEndpoint
+type _ struct {
+	// in:path
+	// appeal id
+	// required:true
+	ID string `json:"id"`
+	// in:body
+	// required:true
+	Body AppealDecision
+}
+
+// swagger:parameters idOfSurvivorListEndpoint
+type _ struct {
+	// in:query
+	// infected or non-infected
+	Status string `json:"status"`
+	// in:query
+	// minimum age
+	MinAge int `json:"min_age"`
+	// in:query
+	// maximum age
+	MaxAge int `json:"max_age"`
+	// in:query
+	// survivors holding the resource item
+	Resource string `json:"resource"`
+	// in:query
+	// name, age or created
+	Sort string `json:"sort"`
+	// in:query
+	// asc or desc
+	Order string `json:"order"`
+	// in:query
+	// cursor of the next page
+	Cursor string `json:"cursor"`
+	// in:query
+	// page size, 20 by default
+	Limit int `json:"limit"`
+}
+
+// swagger:parameters idOfSurvivorLocationsEndpoint
+type _ struct {
+	// in:path
+	// survivor id
+	// required:true
+	ID string `json:"id"`
+	// in:query
+	// start of the time range, RFC3339
+	From string `json:"from"`
+	// in:query
+	// end of the time range, RFC3339
+	To string `json:"to"`
+	// in:query
+	// json or geojson
+	Format string `json:"format"`
+}
+
+// swagger:parameters idOfSurvivorNearbyEndpoint
+type _ struct {
+	// in:query
+	// latitude
+	// required:true
+	Lat float64 `json:"lat"`
+	// in:query
+	// longitude
+	// required:true
+	Lon float64 `json:"lon"`
+	// in:query
+	// radius in meters
+	// required:true
+	Radius float64 `json:"radius"`
+	// in:query
+	// infected or non-infected
+	Status string `json:"status"`
+	// in:query
+	// maximum number of survivors, 20 by default
+	Limit int `json:"limit"`
+}
+
+// swagger:parameters idOfAPIKeyCreateEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body APIKey
+}
+
+// swagger:parameters idOfAPIKeyRevokeEndpoint
+type _ struct {
+	// in:path
+	// api key id
+	// required:true
+	ID string `json:"id"`
+}
+
+// swagger:parameters idOfLoginEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body Login
+}
+
+// swagger:parameters idOfRefreshEndpoint idOfLogoutEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body Refresh
+}
+
+// swagger:parameters idOfRobotsListEndpoint
+type _ struct {
+	// in:query
+	// Land or Flying
+	Category string `json:"category"`
+	// in:query
+	// model prefix
+	Model string `json:"model"`
+	// in:query
+	// serial number
+	Serial string `json:"serial"`
+	// in:query
+	// manufactured on or after the date (2006-01-02 or RFC3339)
+	From string `json:"from"`
+	// in:query
+	// manufactured on or before the date (2006-01-02 or RFC3339)
+	To string `json:"to"`
+	// in:query
+	// serial, model or manufactured
+	Sort string `json:"sort"`
+	// in:query
+	// asc or desc
+	Order string `json:"order"`
+	// in:query
+	// cursor of the next page
+	Cursor string `json:"cursor"`
+	// in:query
+	// page size, 20 by default
+	Limit int `json:"limit"`
+}
+
+// swagger:parameters idOfSightingCreateEndpoint
+type _ struct {
+	// in:body
+	// required:true
+	Body RobotSighting
+}
+
+// swagger:parameters idOfSightingListEndpoint
+type _ struct {
+	// in:query
+	// latitude of the area center
+	Lat float64 `json:"lat"`
+	// in:query
+	// longitude of the area center
+	Lon float64 `json:"lon"`
+	// in:query
+	// area radius in meters, required with lat and lon
+	Radius float64 `json:"radius"`
+	// in:query
+	// seen on or after the time (RFC3339)
+	From string `json:"from"`
+	// in:query
+	// seen on or before the time (RFC3339)
+	To string `json:"to"`
+	// in:query
+	// serial number of the robot
+	Serial string `json:"serial"`
+	// in:query
+	// Land or Flying
+	Category string `json:"category"`
+	// in:query
+	// maximum number of sightings, 20 by default
+	Limit int `json:"limit"`
+}
+
+// swagger:parameters idOfReportThreatMapEndpoint
+type _ struct {
+	// in:query
+	// cell size in degrees, 0.1 by default
+	Cell float64 `json:"cell"`
+	// in:query
+	// bounding box, all of min_lat, min_lon, max_lat and max_lon or none
+	MinLat float64 `json:"min_lat"`
+	// in:query
+	MinLon float64 `json:"min_lon"`
+	// in:query
+	MaxLat float64 `json:"max_lat"`
+	// in:query
+	MaxLon float64 `json:"max_lon"`
+	// in:query
+	// seen on or after the time (RFC3339), 24 hours before the to time by default
+	From string `json:"from"`
+	// in:query
+	// seen on or before the time (RFC3339), now by default
+	To string `json:"to"`
+	// in:query
+	// Land or Flying
+	Category string `json:"category"`
+	// in:query
+	// json or geojson
+	Format string `json:"format"`
+}
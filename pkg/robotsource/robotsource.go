@@ -0,0 +1,172 @@
+// package robotsource
+// This package will include the sources of the robots feed, the remote feed
+// is fetched over http and a local JSON snapshot can be used as a fallback
+package robotsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// robots feed
+type Feed struct {
+	// name of the source which returned the feed
+	Source string
+	// robots of the feed
+	Robots []models.RobotList
+}
+
+// source of the robots feed
+type Source interface {
+	Fetch(ctx context.Context) (*Feed, error)
+}
+
+// remote http source
+type HTTPSource struct {
+	URL        string
+	Client     *http.Client
+	Retries    int           // retries after the first attempt
+	RetryDelay time.Duration // wait time between the attempts
+}
+
+// initiate new http source
+func NewHTTPSource(url string, timeout time.Duration, retries int, retryDelay time.Duration) *HTTPSource {
+	return &HTTPSource{
+		URL:        url,
+		Client:     &http.Client{Timeout: timeout},
+		Retries:    retries,
+		RetryDelay: retryDelay,
+	}
+}
+
+// fetch the robots feed
+// the network errors and the server errors are retried
+func (source *HTTPSource) Fetch(ctx context.Context) (*Feed, error) {
+	err := fmt.Errorf("no attempt was made to fetch %s", source.URL)
+	for attempt := 0; attempt <= source.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(source.RetryDelay):
+			}
+		}
+
+		var robots []models.RobotList
+		var retry bool
+		robots, retry, err = source.fetch(ctx)
+		if err == nil {
+			return &Feed{Source: source.URL, Robots: robots}, nil
+		}
+		if !retry {
+			break
+		}
+	}
+	return nil, err
+}
+
+// single attempt, reports whether the failure can be retried
+func (source *HTTPSource) fetch(ctx context.Context) ([]models.RobotList, bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	response, err := source.Client.Do(request)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to reach %s: %w", source.URL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, response.StatusCode >= http.StatusInternalServerError, fmt.Errorf("%s responded with %s", source.URL, response.Status)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to read the response of %s: %w", source.URL, err)
+	}
+	robots, err := decode(body)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to decode the response of %s: %w", source.URL, err)
+	}
+	return robots, false, nil
+}
+
+// local JSON snapshot of the feed
+type FileSource struct {
+	Path string
+}
+
+// initiate new file source
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+// read the robots feed from the file
+func (source *FileSource) Fetch(ctx context.Context) (*Feed, error) {
+	body, err := os.ReadFile(source.Path)
+	if err != nil {
+		return nil, err
+	}
+	robots, err := decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %w", source.Path, err)
+	}
+	return &Feed{Source: "file://" + source.Path, Robots: robots}, nil
+}
+
+// fallback source
+// the fallback is used when the primary source fails
+type FallbackSource struct {
+	Primary  Source
+	Fallback Source
+}
+
+// fetch the robots feed from the primary source or from the fallback
+func (source *FallbackSource) Fetch(ctx context.Context) (*Feed, error) {
+	feed, err := source.Primary.Fetch(ctx)
+	if err == nil {
+		return feed, nil
+	}
+	feed, fallbackErr := source.Fallback.Fetch(ctx)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%v, fallback: %v", err, fallbackErr)
+	}
+	return feed, nil
+}
+
+// initiate the source of the configurations
+// the url is fetched with the file as the fallback, either of them can be
+// left empty to use only the other one
+func New(config models.EnvironmentalConfigs) (Source, error) {
+	if config.RobotSourceTimeout < 0 || config.RobotSourceRetries < 0 || config.RobotSourceRetryDelay < 0 {
+		return nil, errors.New("the robot source timeout, retries and retry delay can't be negative")
+	}
+	switch {
+	case config.RobotSourceURL != "" && config.RobotSourceFile != "":
+		return &FallbackSource{
+			Primary:  NewHTTPSource(config.RobotSourceURL, config.RobotSourceTimeout, config.RobotSourceRetries, config.RobotSourceRetryDelay),
+			Fallback: NewFileSource(config.RobotSourceFile),
+		}, nil
+	case config.RobotSourceURL != "":
+		return NewHTTPSource(config.RobotSourceURL, config.RobotSourceTimeout, config.RobotSourceRetries, config.RobotSourceRetryDelay), nil
+	case config.RobotSourceFile != "":
+		return NewFileSource(config.RobotSourceFile), nil
+	}
+	return nil, errors.New("either the robot source url or the robot source file should be configured")
+}
+
+// decode the robots feed
+func decode(body []byte) ([]models.RobotList, error) {
+	var robots []models.RobotList
+	if err := json.Unmarshal(body, &robots); err != nil {
+		return nil, err
+	}
+	return robots, nil
+}
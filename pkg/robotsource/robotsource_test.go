@@ -0,0 +1,98 @@
+package robotsource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"robot-apocalypse/pkg/models"
+	"testing"
+	"time"
+)
+
+const testFeed = `[{"model":"R2","serialNumber":"S1","manufacturedDate":"2021-08-02T13:05:00","category":"Flying"}]`
+
+// server answering with the statuses in order, the feed after them
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= len(statuses) {
+			w.WriteHeader(statuses[requests-1])
+			return
+		}
+		w.Write([]byte(testFeed))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestHTTPSourceRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		fails    bool
+		requests int
+	}{
+		{"server errors are retried", []int{http.StatusBadGateway, http.StatusServiceUnavailable}, 2, false, 3},
+		{"retries run out", []int{http.StatusBadGateway, http.StatusBadGateway}, 1, true, 2},
+		{"client errors are not retried", []int{http.StatusNotFound}, 2, true, 1},
+	}
+	for _, test := range tests {
+		server, requests := newTestServer(t, test.statuses...)
+		source := NewHTTPSource(server.URL, time.Second, test.retries, time.Millisecond)
+
+		feed, err := source.Fetch(context.Background())
+		if test.fails != (err != nil) {
+			t.Errorf("%s: error = %v, want failure %v", test.name, err, test.fails)
+		}
+		if !test.fails && (feed == nil || len(feed.Robots) != 1 || feed.Robots[0].SerialNumber != "S1") {
+			t.Errorf("%s: feed = %+v, want the robot S1", test.name, feed)
+		}
+		if *requests != test.requests {
+			t.Errorf("%s: %d requests, want %d", test.name, *requests, test.requests)
+		}
+	}
+}
+
+func TestFallbackSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "robots.json")
+	if err := os.WriteFile(path, []byte(testFeed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server, _ := newTestServer(t, http.StatusNotFound)
+
+	source := &FallbackSource{
+		Primary:  NewHTTPSource(server.URL, time.Second, 0, 0),
+		Fallback: NewFileSource(path),
+	}
+	feed, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if feed.Source != "file://"+path || len(feed.Robots) != 1 {
+		t.Errorf("feed = %+v, want the robots of the file", feed)
+	}
+}
+
+func TestNewRejectsNegativeSettings(t *testing.T) {
+	configs := map[string]models.EnvironmentalConfigs{
+		"timeout":     {RobotSourceURL: "http://feed", RobotSourceTimeout: -time.Second},
+		"retries":     {RobotSourceURL: "http://feed", RobotSourceRetries: -1},
+		"retry delay": {RobotSourceURL: "http://feed", RobotSourceRetryDelay: -time.Second},
+	}
+	for name, config := range configs {
+		if _, err := New(config); err == nil {
+			t.Errorf("negative %s is accepted", name)
+		}
+	}
+
+	// a fetch without any attempt fails instead of returning no feed
+	source := &HTTPSource{URL: "http://feed", Client: http.DefaultClient, Retries: -1}
+	if feed, err := source.Fetch(context.Background()); err == nil {
+		t.Errorf("fetch without attempts = %+v, want an error", feed)
+	}
+}
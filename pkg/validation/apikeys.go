@@ -0,0 +1,30 @@
+package validation
+
+import (
+	"fmt"
+	"robot-apocalypse/pkg/models"
+)
+
+// validate new api key
+func APIKey(key models.APIKey) error {
+	v := New()
+	if v.Required("name", key.Name) {
+		v.Length("name", key.Name, 1, maxNameLength)
+	}
+	if len(key.Scopes) == 0 {
+		v.Add("scopes", "is required")
+	}
+	scopes := make([]string, 0, len(models.Scopes))
+	for _, scope := range models.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+	for i, scope := range key.Scopes {
+		v.OneOf(fmt.Sprintf("scopes[%d]", i), string(scope), scopes...)
+	}
+	v.OneOf("role", string(key.Role), string(models.RoleSurvivor), string(models.RoleFieldOfficer), string(models.RoleAdmin))
+	// survivor keys are bound to the survivor
+	if key.Role == models.RoleSurvivor {
+		v.id("survivor_id", key.SurvivorID)
+	}
+	return v.Err()
+}
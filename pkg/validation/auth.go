@@ -0,0 +1,18 @@
+package validation
+
+import "robot-apocalypse/pkg/models"
+
+// validate login
+func Login(login models.Login) error {
+	v := New()
+	v.id("id", login.ID)
+	v.Required("password", login.Password)
+	return v.Err()
+}
+
+// validate refresh
+func Refresh(refresh models.Refresh) error {
+	v := New()
+	v.Required("refresh_token", refresh.RefreshToken)
+	return v.Err()
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"robot-apocalypse/pkg/models"
+	"time"
+)
+
+// maximum number of the robots of a sighting
+const maxSightingCount = 10000
+
+// validate robot feed entry
+func Robot(robot models.RobotList) error {
+	v := New()
+	v.Required("serialNumber", robot.SerialNumber)
+	v.Required("model", robot.Model)
+	if v.Required("manufacturedDate", robot.ManufacturedDate) {
+		if _, err := models.ParseRobotDate(robot.ManufacturedDate); err != nil {
+			v.Add("manufacturedDate", "should be a date like 2006-01-02T15:04:05")
+		}
+	}
+	if !IsRobotCategory(robot.Category) {
+		v.Add("category", "should be one of %s, %s", models.RobotCategoryLand, models.RobotCategoryFlying)
+	}
+	return v.Err()
+}
+
+// validate robot sighting
+func Sighting(sighting models.RobotSighting) error {
+	v := New()
+	v.id("reported_by", sighting.ReportedBy)
+	if sighting.SerialNumber == "" && sighting.Model == "" && sighting.Category == "" {
+		v.Add("serial_number", "serial number or model/category is required")
+	}
+	if sighting.Category != "" && !IsRobotCategory(sighting.Category) {
+		v.Add("category", "should be one of %s, %s", models.RobotCategoryLand, models.RobotCategoryFlying)
+	}
+	v.Location("location", sighting.Location)
+	v.Range("count", float64(sighting.Count), 1, maxSightingCount)
+	// small clock differences of the clients are allowed
+	if sighting.SeenAt.After(time.Now().Add(time.Minute)) {
+		v.Add("seen_at", "can't be in the future")
+	}
+	return v.Err()
+}
+
+// check the category is a supported robot category
+func IsRobotCategory(category models.RobotCategory) bool {
+	for _, known := range models.RobotCategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
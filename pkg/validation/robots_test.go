@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"errors"
+	"robot-apocalypse/pkg/models"
+	"testing"
+)
+
+func TestRobot(t *testing.T) {
+	tests := []struct {
+		name   string
+		robot  models.RobotList
+		fields []string
+	}{
+		{
+			name:  "valid robot",
+			robot: models.RobotList{SerialNumber: "S1", Model: "R2", ManufacturedDate: "2021-08-02T13:05:00", Category: models.RobotCategoryFlying},
+		},
+		{
+			name:   "missing fields",
+			robot:  models.RobotList{Category: models.RobotCategoryLand},
+			fields: []string{"serialNumber", "model", "manufacturedDate"},
+		},
+		{
+			name:   "invalid date and category",
+			robot:  models.RobotList{SerialNumber: "S1", Model: "R2", ManufacturedDate: "02/08/2021", Category: "Swimming"},
+			fields: []string{"manufacturedDate", "category"},
+		},
+	}
+	for _, test := range tests {
+		err := Robot(test.robot)
+		if len(test.fields) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		var fieldErrors Errors
+		if !errors.As(err, &fieldErrors) {
+			t.Errorf("%s: error = %v, want the field errors", test.name, err)
+			continue
+		}
+		var fields []string
+		for _, fieldError := range fieldErrors {
+			fields = append(fields, fieldError.Field)
+		}
+		if len(fields) != len(test.fields) {
+			t.Errorf("%s: failed fields %v, want %v", test.name, fields, test.fields)
+			continue
+		}
+		for i := range fields {
+			if fields[i] != test.fields[i] {
+				t.Errorf("%s: failed fields %v, want %v", test.name, fields, test.fields)
+				break
+			}
+		}
+	}
+}
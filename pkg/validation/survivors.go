@@ -0,0 +1,206 @@
+package validation
+
+import (
+	"regexp"
+	"robot-apocalypse/pkg/models"
+	"sort"
+)
+
+// limits of the survivor fields
+const (
+	maxIDLength     = 64
+	maxNameLength   = 100
+	maxAge          = 130
+	maxReasonLength = 500
+	// bcrypt only uses the first 72 bytes of the password
+	minPasswordLength = 8
+	maxPasswordLength = 72
+	// quantity limit of an item in a trade, keeps the trade points far
+	// from the int overflow
+	maxTradeQuantity = 10000
+)
+
+var (
+	// survivor ids
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// survivor names, letters of any language, spaces and a few separators
+	namePattern = regexp.MustCompile(`^[\p{L}\p{M}0-9 .'-]+$`)
+)
+
+// validate new survivor
+// the id is optional, the server generates one when it's missing
+func Survivor(sr models.Survivor) error {
+	v := New()
+	if sr.ID != "" {
+		v.id("id", sr.ID)
+	}
+	if v.Required("name", sr.Name) {
+		v.name("name", sr.Name)
+	}
+	v.Range("age", float64(sr.Age), 0, maxAge)
+	v.Location("location", sr.Location)
+	v.Resources("resources", sr.Resources, false)
+	// the password is optional, survivors without a password can't login
+	if sr.Password != "" && (len(sr.Password) < minPasswordLength || len(sr.Password) > maxPasswordLength) {
+		v.Add("password", "should be between %d and %d bytes", minPasswordLength, maxPasswordLength)
+	}
+	return v.Err()
+}
+
+// validate survivor update
+// only the id is required, the zero values are left unchanged
+func SurvivorUpdate(sr models.Survivor) error {
+	v := New()
+	v.id("id", sr.ID)
+	if sr.Name != "" {
+		v.name("name", sr.Name)
+	}
+	if sr.Age != 0 {
+		v.Range("age", float64(sr.Age), 1, maxAge)
+	}
+	v.Location("location", sr.Location)
+	v.Resources("resources", sr.Resources, false)
+	return v.Err()
+}
+
+// validate infection report
+func SurvivorInfected(sr models.SurvivorInfected) error {
+	v := New()
+	v.id("id", sr.ID)
+	if v.id("reported_by", sr.ReportedBy) && sr.ID == sr.ReportedBy {
+		v.Add("reported_by", "survivor can't report themselves")
+	}
+	v.Length("reason", sr.Reason, 0, maxReasonLength)
+	if sr.Location != nil {
+		v.Location("location", *sr.Location)
+	}
+	return v.Err()
+}
+
+// validate infection report retraction
+func InfectionRetraction(sr models.SurvivorInfected) error {
+	v := New()
+	v.id("id", sr.ID)
+	v.id("reported_by", sr.ReportedBy)
+	return v.Err()
+}
+
+// validate inventory adjustment
+// negative quantities removes the items
+func InventoryAdjustment(adjustment models.InventoryAdjustment) error {
+	v := New()
+	if len(adjustment.Items) == 0 {
+		v.Add("items", "is required")
+	}
+	v.Resources("items", adjustment.Items, true)
+	return v.Err()
+}
+
+// validate trade
+func Trade(trade models.Trade) error {
+	v := New()
+	offers := []struct {
+		field string
+		offer models.TradeOffer
+	}{
+		{"from", trade.From},
+		{"to", trade.To},
+	}
+	for _, entry := range offers {
+		v.id(entry.field+".id", entry.offer.ID)
+		if len(entry.offer.Items) == 0 {
+			v.Add(entry.field+".items", "is required")
+		}
+		v.Resources(entry.field+".items", entry.offer.Items, false)
+		// the negative quantities are reported by the resources check
+		for _, item := range sortedItems(entry.offer.Items) {
+			if quantity := entry.offer.Items[item]; quantity == 0 {
+				v.Add(entry.field+".items."+string(item), "quantity should be greater than 0")
+			} else if quantity > maxTradeQuantity {
+				v.Add(entry.field+".items."+string(item), "quantity should be at most %d", maxTradeQuantity)
+			}
+		}
+	}
+	if trade.From.ID != "" && trade.From.ID == trade.To.ID {
+		v.Add("to.id", "survivor can't trade with themselves")
+	}
+	return v.Err()
+}
+
+// validate appeal
+func Appeal(appeal models.Appeal) error {
+	v := New()
+	if v.Required("reason", appeal.Reason) {
+		v.Length("reason", appeal.Reason, 1, maxReasonLength)
+	}
+	return v.Err()
+}
+
+// validate appeal decision
+func AppealDecision(decision models.AppealDecision) error {
+	v := New()
+	v.OneOf("decision", decision.Decision, "uphold", "dismiss")
+	return v.Err()
+}
+
+// validate trade decision
+func TradeDecision(decision models.TradeDecision) error {
+	v := New()
+	v.OneOf("decision", decision.Decision, "accept", "decline")
+	return v.Err()
+}
+
+// location coordinates should be valid for a GeoJSON point
+func (v *Validator) Location(field string, location models.Location) {
+	v.Range(field+".latitude", float64(location.Latitude), -90, 90)
+	v.Range(field+".longitude", float64(location.Longitude), -180, 180)
+}
+
+// inventory items should be known, negative quantities are only allowed
+// for the adjustments
+func (v *Validator) Resources(field string, resources models.Resources, allowNegative bool) {
+	for _, item := range sortedItems(resources) {
+		quantity := resources[item]
+		if !IsResourceItem(item) {
+			v.Add(field+"."+string(item), "unknown resource item")
+			continue
+		}
+		if quantity < 0 && !allowNegative {
+			v.Add(field+"."+string(item), "quantity can't be negative")
+		}
+	}
+}
+
+// items of the resources sorted by name, so the errors are reported in the
+// same order
+func sortedItems(resources models.Resources) []models.ResourceItem {
+	items := make([]models.ResourceItem, 0, len(resources))
+	for item := range resources {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i] < items[j] })
+	return items
+}
+
+// check the item is a supported resource item
+func IsResourceItem(item models.ResourceItem) bool {
+	for _, known := range models.ResourceItems {
+		if item == known {
+			return true
+		}
+	}
+	return false
+}
+
+// survivor id
+func (v *Validator) id(field string, value string) bool {
+	return v.Required(field, value) &&
+		v.Length(field, value, 1, maxIDLength) &&
+		v.Match(field, value, idPattern, "letters, digits, '-' and '_'")
+}
+
+// survivor name
+func (v *Validator) name(field string, value string) bool {
+	return v.Length(field, value, 1, maxNameLength) &&
+		v.Match(field, value, namePattern, "letters, digits, spaces and . ' -")
+}
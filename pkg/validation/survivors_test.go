@@ -0,0 +1,76 @@
+package validation
+
+import (
+	"errors"
+	"math"
+	"robot-apocalypse/pkg/models"
+	"testing"
+)
+
+func TestTrade(t *testing.T) {
+	tests := []struct {
+		name   string
+		trade  models.Trade
+		fields []string
+	}{
+		{
+			name: "valid trade",
+			trade: models.Trade{
+				From: models.TradeOffer{ID: "srv1", Items: models.Resources{models.Water: 1}},
+				To:   models.TradeOffer{ID: "srv2", Items: models.Resources{models.Food: 1, models.Medication: 2}},
+			},
+		},
+		{
+			name: "zero and negative quantities",
+			trade: models.Trade{
+				From: models.TradeOffer{ID: "srv1", Items: models.Resources{models.Water: 0, models.Food: -1}},
+				To:   models.TradeOffer{ID: "srv2", Items: models.Resources{models.Medication: 0}},
+			},
+			fields: []string{"from.items.food", "from.items.water", "to.items.medication"},
+		},
+		{
+			name: "quantities over the limit",
+			trade: models.Trade{
+				From: models.TradeOffer{ID: "srv1", Items: models.Resources{models.Water: maxTradeQuantity + 1}},
+				To:   models.TradeOffer{ID: "srv2", Items: models.Resources{models.Ammunition: math.MaxInt}},
+			},
+			fields: []string{"from.items.water", "to.items.ammunition"},
+		},
+		{
+			name: "unknown items and the same survivor",
+			trade: models.Trade{
+				From: models.TradeOffer{ID: "srv1", Items: models.Resources{"gold": 1}},
+				To:   models.TradeOffer{ID: "srv1"},
+			},
+			fields: []string{"from.items.gold", "to.items", "to.id"},
+		},
+	}
+	for _, test := range tests {
+		err := Trade(test.trade)
+		var fieldErrors Errors
+		if len(test.fields) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &fieldErrors) {
+			t.Errorf("%s: error = %v, want the field errors", test.name, err)
+			continue
+		}
+		var fields []string
+		for _, fieldError := range fieldErrors {
+			fields = append(fields, fieldError.Field)
+		}
+		if len(fields) != len(test.fields) {
+			t.Errorf("%s: failed fields %v, want %v", test.name, fields, test.fields)
+			continue
+		}
+		for i := range fields {
+			if fields[i] != test.fields[i] {
+				t.Errorf("%s: failed fields %v, want %v", test.name, fields, test.fields)
+				break
+			}
+		}
+	}
+}
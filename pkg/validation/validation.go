@@ -0,0 +1,100 @@
+// package validation
+// This package will include the request payload validations, every failed
+// rule is collected as a field error, so the client gets all of them at once
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"robot-apocalypse/pkg/models"
+	"strings"
+	"unicode/utf8"
+)
+
+// list of field errors
+// returned by the validations when one or more rules failed
+type Errors []models.FieldError
+
+// error message
+func (errs Errors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, fieldError := range errs {
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// validator
+// collects the field errors of a payload
+type Validator struct {
+	errs Errors
+}
+
+// initiate new validator
+func New() *Validator {
+	return &Validator{}
+}
+
+// add a field error
+func (v *Validator) Add(field string, format string, args ...interface{}) {
+	v.errs = append(v.errs, models.FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// validation result, nil when all the rules passed
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// the value should not be empty
+func (v *Validator) Required(field string, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, "is required")
+		return false
+	}
+	return true
+}
+
+// the value length should be between min and max characters
+func (v *Validator) Length(field string, value string, min int, max int) bool {
+	length := utf8.RuneCountInString(value)
+	if length < min || length > max {
+		v.Add(field, "should be between %d and %d characters", min, max)
+		return false
+	}
+	return true
+}
+
+// the value should match the pattern
+func (v *Validator) Match(field string, value string, pattern *regexp.Regexp, description string) bool {
+	if !pattern.MatchString(value) {
+		v.Add(field, "should contain only %s", description)
+		return false
+	}
+	return true
+}
+
+// the value should be between min and max
+func (v *Validator) Range(field string, value float64, min float64, max float64) bool {
+	if value < min || value > max {
+		v.Add(field, "should be between %v and %v", min, max)
+		return false
+	}
+	return true
+}
+
+// the value should be one of the options
+func (v *Validator) OneOf(field string, value string, options ...string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	v.Add(field, "should be one of %s", strings.Join(options, ", "))
+	return false
+}